@@ -0,0 +1,447 @@
+package wengine
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Binding is an input that triggers a button action, written as a key name
+// of GetKey with optional modifiers in front, e.g. "space", "ctrl+s",
+// "ctrl+shift+z" or "gamepad 1 a". The modifiers are ctrl, shift, alt and
+// super, each matching both the left and the right key.
+type Binding string
+
+var modifierKeys = map[string][]string{
+	"ctrl":  {"left control", "right control"},
+	"shift": {"left shift", "right shift"},
+	"alt":   {"left alt", "right alt"},
+	"super": {"left super", "right super"},
+}
+
+// modifierOrder is the order modifiers are written in by NewBinding.
+var modifierOrder = []string{"ctrl", "shift", "alt", "super"}
+
+func NewBinding(key string, modifiers ...string) Binding {
+	parts := []string{}
+	for _, modifier := range modifierOrder {
+		for _, m := range modifiers {
+			if m == modifier {
+				parts = append(parts, modifier)
+				break
+			}
+		}
+	}
+	return Binding(strings.Join(append(parts, key), "+"))
+}
+
+// Parse splits the binding into its key and modifiers. Only known modifiers
+// are split off, so keys such as "keypad +" are kept whole.
+func (b Binding) Parse() (key string, modifiers []string) {
+	key = string(b)
+	for {
+		i := strings.Index(key, "+")
+		if i <= 0 || i == len(key)-1 {
+			return
+		}
+		if _, ok := modifierKeys[key[:i]]; !ok {
+			return
+		}
+		modifiers = append(modifiers, key[:i])
+		key = key[i+1:]
+	}
+}
+
+// ActionMap is a named group of actions that are enabled and disabled
+// together, such as "gameplay" or "menu".
+type ActionMap struct {
+	Name string `json:"name"`
+	// Buttons are button actions and what triggers them.
+	Buttons map[string][]Binding `json:"buttons,omitempty"`
+	// Axes are axis actions, combined as in BindAxis.
+	Axes map[string][]AxisMeta `json:"axes,omitempty"`
+
+	enabled bool
+}
+
+func NewActionMap(name string) *ActionMap {
+	return &ActionMap{Name: name, Buttons: map[string][]Binding{}, Axes: map[string][]AxisMeta{}}
+}
+
+func (m *ActionMap) BindButton(action string, bindings ...Binding) {
+	m.initMaps()
+	m.Buttons[action] = append(m.Buttons[action], bindings...)
+}
+
+func (m *ActionMap) BindAxis(action string, meta AxisMeta) {
+	m.initMaps()
+	m.Axes[action] = append(m.Axes[action], meta)
+}
+
+// initMaps makes the maps of an ActionMap not made by NewActionMap.
+func (m *ActionMap) initMaps() {
+	if m.Buttons == nil {
+		m.Buttons = map[string][]Binding{}
+	}
+	if m.Axes == nil {
+		m.Axes = map[string][]AxisMeta{}
+	}
+}
+
+func (m *ActionMap) Enabled() bool {
+	return m.enabled
+}
+
+// actionAxisName is the name the axis of an action is bound under in Input.
+func actionAxisName(mapName, action string) string {
+	return mapName + "/" + action
+}
+
+// AddActionMap adds a disabled action map, replacing the one with the same
+// name.
+func (i *Input) AddActionMap(m *ActionMap) {
+	if old := i.actionMaps[m.Name]; old != nil {
+		i.DisableActionMap(old.Name)
+	}
+	m.initMaps()
+	m.enabled = false
+	i.actionMaps[m.Name] = m
+}
+
+func (i *Input) ActionMap(name string) *ActionMap {
+	return i.actionMaps[name]
+}
+
+func (i *Input) EnableActionMap(name string) {
+	m := i.actionMaps[name]
+	if m == nil || m.enabled {
+		return
+	}
+	m.enabled = true
+	for action, metas := range m.Axes {
+		for _, meta := range metas {
+			i.BindAxis(actionAxisName(m.Name, action), meta)
+		}
+	}
+}
+
+func (i *Input) DisableActionMap(name string) {
+	m := i.actionMaps[name]
+	if m == nil || !m.enabled {
+		return
+	}
+	m.enabled = false
+	for action := range m.Axes {
+		i.ResetAxis(actionAxisName(m.Name, action))
+	}
+}
+
+// SwitchActionMap enables the named action map and disables all others.
+func (i *Input) SwitchActionMap(name string) {
+	for other := range i.actionMaps {
+		if other != name {
+			i.DisableActionMap(other)
+		}
+	}
+	i.EnableActionMap(name)
+}
+
+// refreshActionMap rebinds the axes of an enabled map after they changed.
+func (i *Input) refreshActionMap(m *ActionMap) {
+	if m.enabled {
+		i.DisableActionMap(m.Name)
+		i.EnableActionMap(m.Name)
+	}
+}
+
+// GetAction reports whether a button action of an enabled map is held.
+func (i *Input) GetAction(action string) bool {
+	return i.curActionState[action]
+}
+
+func (i *Input) GetActionDown(action string) bool {
+	return i.curActionState[action] && !i.preActionState[action]
+}
+
+func (i *Input) GetActionUp(action string) bool {
+	return !i.curActionState[action] && i.preActionState[action]
+}
+
+// GetActionAxis returns the value of an axis action of the enabled maps,
+// the one furthest from zero if several maps have it.
+func (i *Input) GetActionAxis(action string) (final float64) {
+	for _, m := range i.actionMaps {
+		if !m.enabled {
+			continue
+		}
+		if value := i.GetAxis(actionAxisName(m.Name, action)); value*value > final*final {
+			final = value
+		}
+	}
+	return
+}
+
+func (i *Input) modifierHeld(modifier string) bool {
+	for _, key := range modifierKeys[modifier] {
+		if i.GetKey(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (i *Input) bindingHeld(key string, modifiers []string) bool {
+	if !i.GetKey(key) {
+		return false
+	}
+	for _, modifier := range modifiers {
+		if !i.modifierHeld(modifier) {
+			return false
+		}
+	}
+	return true
+}
+
+// updateActions works out the button actions held in the frame. A binding
+// is ignored while a held binding of the same key has more modifiers, so
+// that "s" does not fire on "ctrl+s", and while its key was pressed to
+// listen for a binding, so that a rebound action does not fire on the keys
+// it was bound with.
+func (i *Input) updateActions() {
+	for key := range i.listenedKeys {
+		if !i.GetKey(key) {
+			delete(i.listenedKeys, key)
+		}
+	}
+	type heldBinding struct {
+		action    string
+		key       string
+		modifiers []string
+	}
+	held := []heldBinding{}
+	for _, m := range i.actionMaps {
+		if !m.enabled {
+			continue
+		}
+		for action, bindings := range m.Buttons {
+			for _, binding := range bindings {
+				key, modifiers := binding.Parse()
+				if !i.listenedKeys[key] && i.bindingHeld(key, modifiers) {
+					held = append(held, heldBinding{action: action, key: key, modifiers: modifiers})
+				}
+			}
+		}
+	}
+
+	for action := range i.curActionState {
+		i.curActionState[action] = false
+	}
+	for _, b := range held {
+		shadowed := false
+		for _, other := range held {
+			if other.key == b.key && len(other.modifiers) > len(b.modifiers) {
+				shadowed = true
+				break
+			}
+		}
+		if !shadowed {
+			i.curActionState[b.action] = true
+		}
+	}
+}
+
+// ListenForBinding calls fn with the next binding the player presses: the
+// first key or button pressed that is not a modifier, with the modifiers
+// held at the time, or a modifier alone if it is released before any other
+// key. Listening stops when fn returns true, so fn can reject a binding,
+// e.g. "esc", by returning false. Keys pressed while listening trigger no
+// action until they are released.
+func (i *Input) ListenForBinding(fn func(binding Binding) bool) {
+	i.bindingListener = fn
+}
+
+func (i *Input) StopListening() {
+	i.bindingListener = nil
+}
+
+// RebindButton replaces the binding of a button action at index, or adds
+// one if index is out of range, with the next binding pressed. done, if not
+// nil, is called with it.
+func (i *Input) RebindButton(mapName, action string, index int, done func(binding Binding)) error {
+	m := i.actionMaps[mapName]
+	if m == nil {
+		return errors.New("no such action map: " + mapName)
+	}
+	i.ListenForBinding(func(binding Binding) bool {
+		bindings := m.Buttons[action]
+		if index >= 0 && index < len(bindings) {
+			bindings[index] = binding
+		} else {
+			m.BindButton(action, binding)
+		}
+		if done != nil {
+			done(binding)
+		}
+		return true
+	})
+	return nil
+}
+
+// RebindAxisKey replaces the positive or negative key of the axis binding
+// at index of an axis action with the next key pressed. Modifiers are not
+// supported by axes and are dropped.
+func (i *Input) RebindAxisKey(mapName, action string, index int, positive bool, done func(key string)) error {
+	m := i.actionMaps[mapName]
+	if m == nil {
+		return errors.New("no such action map: " + mapName)
+	}
+	if index < 0 || index >= len(m.Axes[action]) {
+		return errors.New("no such axis binding of " + action)
+	}
+	i.ListenForBinding(func(binding Binding) bool {
+		key, _ := binding.Parse()
+		meta := &m.Axes[action][index]
+		if positive {
+			meta.PositiveKey = key
+		} else {
+			meta.NegativeKey = key
+		}
+		i.refreshActionMap(m)
+		if done != nil {
+			done(key)
+		}
+		return true
+	})
+	return nil
+}
+
+func isModifierKey(key string) bool {
+	for _, keys := range modifierKeys {
+		for _, k := range keys {
+			if k == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// listenForBinding feeds the events of the frame to the binding listener.
+func (i *Input) listenForBinding() {
+	for _, event := range i.frameEvents {
+		if i.bindingListener == nil {
+			return
+		}
+		var key string
+		switch event.Type {
+		case INPUT_EVENT_KEY, INPUT_EVENT_BUTTON:
+			key = event.Key
+		case INPUT_EVENT_JOYSTICK_BUTTON:
+			key = i.JoystickButtonKey(event.Device, event.Axis)
+		default:
+			continue
+		}
+		if event.State == KEY_STATE_DOWN {
+			i.listenedKeys[key] = true
+		}
+
+		var binding Binding
+		switch {
+		case event.State == KEY_STATE_DOWN && !isModifierKey(key):
+			modifiers := []string{}
+			for _, modifier := range modifierOrder {
+				if i.modifierHeld(modifier) {
+					modifiers = append(modifiers, modifier)
+				}
+			}
+			binding = NewBinding(key, modifiers...)
+			i.modifierPressedAlone = ""
+		case event.State == KEY_STATE_DOWN:
+			i.modifierPressedAlone = key
+			continue
+		case key == i.modifierPressedAlone:
+			binding = Binding(key)
+			i.modifierPressedAlone = ""
+		default:
+			continue
+		}
+
+		listener := i.bindingListener
+		i.bindingListener = nil
+		// keep listening on rejection, unless fn started listening anew
+		if !listener(binding) && i.bindingListener == nil {
+			i.bindingListener = listener
+		}
+	}
+}
+
+type bindingsFile struct {
+	Version int          `json:"version"`
+	Maps    []*ActionMap `json:"maps"`
+}
+
+// SaveBindings writes the bindings of every action map.
+func (i *Input) SaveBindings(w io.Writer) error {
+	file := bindingsFile{Version: 1}
+	for _, m := range i.actionMaps {
+		file.Maps = append(file.Maps, m)
+	}
+	sort.Slice(file.Maps, func(a, b int) bool {
+		return file.Maps[a].Name < file.Maps[b].Name
+	})
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(&file)
+}
+
+// LoadBindings reads bindings written by SaveBindings. Actions found in the
+// file replace the bindings of the actions with the same name; maps not yet
+// added are added disabled.
+func (i *Input) LoadBindings(r io.Reader) error {
+	file := bindingsFile{}
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return err
+	}
+	if file.Version != 1 {
+		return errors.New("unsupported bindings file version")
+	}
+	for _, loaded := range file.Maps {
+		m := i.actionMaps[loaded.Name]
+		if m == nil {
+			m = NewActionMap(loaded.Name)
+			i.actionMaps[m.Name] = m
+		}
+		for action, bindings := range loaded.Buttons {
+			m.Buttons[action] = bindings
+		}
+		for action, metas := range loaded.Axes {
+			m.Axes[action] = metas
+		}
+		i.refreshActionMap(m)
+	}
+	return nil
+}
+
+func (i *Input) SaveBindingsFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := i.SaveBindings(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (i *Input) LoadBindingsFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return i.LoadBindings(file)
+}
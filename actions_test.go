@@ -0,0 +1,185 @@
+package wengine
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newActionInput has a gameplay map with a jump button and a move axis
+// enabled.
+func newActionInput() *Input {
+	input := newInput()
+	m := NewActionMap("gameplay")
+	m.BindButton("jump", "space")
+	m.BindAxis("move", AxisMeta{Source: AXIS_SOURCE_KEY, PositiveKey: "d", NegativeKey: "a", Gravity: 100, Sensitivity: 100})
+	input.AddActionMap(m)
+	input.EnableActionMap("gameplay")
+	return input
+}
+
+func TestBindingParse(t *testing.T) {
+	tests := []struct {
+		binding       Binding
+		wantKey       string
+		wantModifiers []string
+	}{
+		{"space", "space", nil},
+		{"ctrl+s", "s", []string{"ctrl"}},
+		{"ctrl+shift+z", "z", []string{"ctrl", "shift"}},
+		{"keypad +", "keypad +", nil},
+		{"ctrl+keypad +", "keypad +", []string{"ctrl"}},
+		{"gamepad 1 a", "gamepad 1 a", nil},
+		{"foo+bar", "foo+bar", nil},
+	}
+	for _, test := range tests {
+		t.Run(string(test.binding), func(t *testing.T) {
+			key, modifiers := test.binding.Parse()
+			if key != test.wantKey || !reflect.DeepEqual(modifiers, test.wantModifiers) {
+				t.Errorf("Parse() = %q, %v, want %q, %v", key, modifiers, test.wantKey, test.wantModifiers)
+			}
+		})
+	}
+}
+
+func TestRebindButton(t *testing.T) {
+	tests := []struct {
+		name  string
+		index int
+		// keys are pressed in order in the first frame
+		keys []string
+		want []Binding
+	}{
+		{"replace", 0, []string{"j"}, []Binding{"j"}},
+		{"add", 1, []string{"j"}, []Binding{"space", "j"}},
+		{"with modifiers", 0, []string{"left shift", "left control", "j"}, []Binding{"ctrl+shift+j"}},
+		{"modifier alone", 0, []string{"left shift"}, []Binding{"left shift"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := newActionInput()
+			var done Binding
+			err := input.RebindButton("gameplay", "jump", test.index, func(binding Binding) { done = binding })
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, key := range test.keys {
+				input.InjectKey(key, true)
+			}
+			input.frameStart(1)
+			if input.GetAction("jump") {
+				t.Error("jump fired on the keys it was bound with")
+			}
+			input.frameEnd()
+			// a modifier alone is bound on release
+			for _, key := range test.keys {
+				input.InjectKey(key, false)
+			}
+			input.frameStart(2)
+			input.frameEnd()
+
+			if got := input.ActionMap("gameplay").Buttons["jump"]; !reflect.DeepEqual(got, test.want) {
+				t.Errorf("bindings = %v, want %v", got, test.want)
+			}
+			if want := test.want[len(test.want)-1]; done != want {
+				t.Errorf("done with %q, want %q", done, want)
+			}
+
+			for _, key := range test.keys {
+				input.InjectKey(key, true)
+			}
+			input.frameStart(3)
+			if !input.GetActionDown("jump") {
+				t.Error("jump not fired on a new press of its binding")
+			}
+		})
+	}
+}
+
+func TestRebindAxisKey(t *testing.T) {
+	input := newActionInput()
+	if err := input.RebindAxisKey("gameplay", "move", 1, true, nil); err == nil {
+		t.Error("no error for a missing axis binding")
+	}
+	if err := input.RebindAxisKey("gameplay", "move", 0, true, nil); err != nil {
+		t.Fatal(err)
+	}
+	input.InjectKey("left control", true)
+	input.InjectKey("right", true)
+	input.frameStart(1)
+	input.frameEnd()
+	if key := input.ActionMap("gameplay").Axes["move"][0].PositiveKey; key != "right" {
+		t.Errorf("positive key = %q, want right", key)
+	}
+}
+
+func TestActionMapLiteral(t *testing.T) {
+	input := newInput()
+	m := &ActionMap{Name: "menu"}
+	m.BindButton("back", "escape")
+	input.AddActionMap(&ActionMap{Name: "other"})
+	if err := input.RebindButton("other", "ok", 0, nil); err != nil {
+		t.Fatal(err)
+	}
+	input.InjectKey("enter", true)
+	input.frameStart(1)
+	if got := input.ActionMap("other").Buttons["ok"]; !reflect.DeepEqual(got, []Binding{"enter"}) {
+		t.Errorf("bindings = %v", got)
+	}
+}
+
+func TestBindingsRoundTrip(t *testing.T) {
+	input := newActionInput()
+	menu := NewActionMap("menu")
+	menu.BindButton("back", "escape", "gamepad 1 b")
+	input.AddActionMap(menu)
+	buf := &bytes.Buffer{}
+	if err := input.SaveBindings(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := newInput()
+	gameplay := NewActionMap("gameplay")
+	gameplay.BindButton("jump", "w")
+	gameplay.BindButton("crouch", "c")
+	loaded.AddActionMap(gameplay)
+	loaded.EnableActionMap("gameplay")
+	if err := loaded.LoadBindings(buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := gameplay.Buttons["jump"]; !reflect.DeepEqual(got, []Binding{"space"}) {
+		t.Errorf("jump = %v, want [space]", got)
+	}
+	if got := gameplay.Buttons["crouch"]; !reflect.DeepEqual(got, []Binding{"c"}) {
+		t.Errorf("crouch = %v, want the binding kept", got)
+	}
+	if m := loaded.ActionMap("menu"); m == nil || m.Enabled() || !reflect.DeepEqual(m.Buttons["back"], menu.Buttons["back"]) {
+		t.Errorf("menu = %+v", m)
+	}
+	loaded.InjectKey("d", true)
+	loaded.frameStart(1)
+	loaded.frameEnd()
+	loaded.frameStart(2)
+	if loaded.GetActionAxis("move") <= 0 {
+		t.Error("loaded axis not bound")
+	}
+}
+
+func TestLoadBindingsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+	}{
+		{"not json", "bindings"},
+		{"version 0", `{"version": 0, "maps": []}`},
+		{"future version", `{"version": 2, "maps": []}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := newInput().LoadBindings(strings.NewReader(test.file)); err == nil {
+				t.Error("no error")
+			}
+		})
+	}
+}
@@ -156,6 +156,9 @@ type MeshMaterialAsset struct {
 	DiffuseColor     mgl32.Vec4
 	DiffuseMapPath   string
 	DiffuseMapBuffer []byte
+	// RenderTexture names a RenderTextureAsset sampled instead of the
+	// diffuse map.
+	RenderTexture string
 
 	DiffuseImage *image.RGBA
 }
@@ -191,12 +194,15 @@ func (m *MeshMaterialAsset) load() error {
 type SpriteMaterialAsset struct {
 	TexturePath   string
 	TextureBuffer []byte
+	// RenderTexture names a RenderTextureAsset sampled instead of the
+	// texture.
+	RenderTexture string
 
 	TextureImage *image.RGBA
 }
 
 func (m *SpriteMaterialAsset) Loaded() bool {
-	return m.TextureImage != nil
+	return m.RenderTexture != "" || m.TextureImage != nil
 }
 
 func (m *SpriteMaterialAsset) load() error {
@@ -222,6 +228,30 @@ func (m *SpriteMaterialAsset) load() error {
 
 // -----------------------------------------------------------
 
+const (
+	TEXTURE_FORMAT_RGBA8 = iota
+	TEXTURE_FORMAT_RGBA16F
+	TEXTURE_FORMAT_RGBA32F
+)
+
+// RenderTextureAsset is a texture drawn by the cameras targeting it, which
+// materials can sample. It has no data to load; renderers allocate it on
+// first use and reallocate it when its size or format changes.
+type RenderTextureAsset struct {
+	Width, Height int
+	Format        int
+}
+
+func (t *RenderTextureAsset) Loaded() bool {
+	return true
+}
+
+func (t *RenderTextureAsset) load() error {
+	return nil
+}
+
+// -----------------------------------------------------------
+
 type ShaderAsset struct {
 	VertexSource, GeometrySource, FragmentSource string
 }
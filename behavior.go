@@ -0,0 +1,234 @@
+package wengine
+
+import (
+	"reflect"
+	"sort"
+)
+
+type BehaviorContext struct {
+	Context *Context
+	Object  *Object
+
+	// scaled by the time scale, zero while paused
+	DeltaTime float64
+	Time      float64
+
+	UnscaledDeltaTime float64
+	UnscaledTime      float64
+	FrameCount        int
+
+	// Alpha is how far the frame is between the last fixed step and the next
+	// one, for interpolating state simulated in FixedUpdate.
+	Alpha float64
+}
+
+type Behavior interface {
+	Start(bctx *BehaviorContext)
+	Update(bctx *BehaviorContext)
+}
+
+// FixedUpdateHandler can be implemented by a behavior to be called at a fixed
+// time step, before Update.
+type FixedUpdateHandler interface {
+	FixedUpdate(bctx *BehaviorContext)
+}
+
+// LateUpdateHandler can be implemented by a behavior to be called after
+// every Update of the frame has run.
+type LateUpdateHandler interface {
+	LateUpdate(bctx *BehaviorContext)
+}
+
+// EnableHandler can be implemented by a behavior to be notified when its
+// object becomes active in hierarchy.
+type EnableHandler interface {
+	OnEnable(bctx *BehaviorContext)
+}
+
+// DisableHandler can be implemented by a behavior to be notified when its
+// object stops being active in hierarchy.
+type DisableHandler interface {
+	OnDisable(bctx *BehaviorContext)
+}
+
+// DestroyHandler can be implemented by a behavior to be notified when its
+// object is removed from the scene by Scene.Destroy.
+type DestroyHandler interface {
+	OnDestroy(bctx *BehaviorContext)
+}
+
+// JoystickHandler can be implemented by a behavior to be notified when a
+// joystick is connected or disconnected, before the updates of the frame.
+type JoystickHandler interface {
+	OnJoystickConnect(bctx *BehaviorContext, device int)
+	OnJoystickDisconnect(bctx *BehaviorContext, device int)
+}
+
+// ResizeHandler can be implemented by a behavior to be notified when the
+// screen size changed, before the updates of the frame.
+type ResizeHandler interface {
+	OnResize(bctx *BehaviorContext, width, height int)
+}
+
+// ExecutionOrderer can be implemented by a behavior to run before (lower
+// values) or after (higher values) other behaviors. The default order is 0.
+type ExecutionOrderer interface {
+	ExecutionOrder() int
+}
+
+type behaviorSlot struct {
+	behavior Behavior
+
+	started bool
+	// activeNotified is the active state last reported through
+	// OnEnable / OnDisable.
+	activeNotified bool
+}
+
+type behaviorRef struct {
+	object *Object
+	slot   *behaviorSlot
+	order  int
+}
+
+// SetExecutionOrder sets the execution order of every behavior of the same
+// type as behavior, taking precedence over ExecutionOrderer.
+func (ctx *Context) SetExecutionOrder(behavior Behavior, order int) {
+	ctx.executionOrders[reflect.TypeOf(behavior)] = order
+}
+
+func (ctx *Context) executionOrder(behavior Behavior) int {
+	if order, exists := ctx.executionOrders[reflect.TypeOf(behavior)]; exists {
+		return order
+	}
+	if orderer, ok := behavior.(ExecutionOrderer); ok {
+		return orderer.ExecutionOrder()
+	}
+	return 0
+}
+
+// sortedBehaviors lists the behaviors of the scene by execution order, then
+// by object name, then by the order they were added to their object.
+func (ctx *Context) sortedBehaviors(scene *Scene) []behaviorRef {
+	refs := []behaviorRef{}
+	for _, obj := range scene.objects {
+		for _, slot := range obj.behaviors {
+			refs = append(refs, behaviorRef{object: obj, slot: slot, order: ctx.executionOrder(slot.behavior)})
+		}
+	}
+	sort.SliceStable(refs, func(i, j int) bool {
+		if refs[i].order != refs[j].order {
+			return refs[i].order < refs[j].order
+		}
+		return refs[i].object.name < refs[j].object.name
+	})
+	return refs
+}
+
+// executeBehaviors starts behaviors that have not been started yet and,
+// unless runStart is set, runs the fixed updates due, Update and then
+// LateUpdate on them. Objects instantiated during the pass are handled in the
+// next frame.
+func (ctx *Context) executeBehaviors(runStart bool) {
+	refs := ctx.sortedBehaviors(ctx.currentScene)
+
+	active := make([]behaviorRef, 0, len(refs))
+	for _, ref := range refs {
+		// changes are notified as they happen once the scene is applied,
+		// this catches behaviors added since and objects set up before
+		ctx.notifyActive(ref.object, ref.slot)
+		if !ref.object.ActiveInHierarchy() {
+			continue
+		}
+		if !ref.slot.started {
+			ref.slot.started = true
+			bctx := ctx.behaviorContext(ref.object)
+			ref.slot.behavior.Start(&bctx)
+		}
+		active = append(active, ref)
+	}
+	if runStart {
+		return
+	}
+
+	for _, event := range ctx.input.frameEvents {
+		if event.Type != INPUT_EVENT_JOYSTICK_CONNECT && event.Type != INPUT_EVENT_JOYSTICK_DISCONNECT {
+			continue
+		}
+		for _, ref := range active {
+			handler, ok := ref.slot.behavior.(JoystickHandler)
+			if !ok {
+				continue
+			}
+			bctx := ctx.behaviorContext(ref.object)
+			if event.Type == INPUT_EVENT_JOYSTICK_CONNECT {
+				handler.OnJoystickConnect(&bctx, event.Device)
+			} else {
+				handler.OnJoystickDisconnect(&bctx, event.Device)
+			}
+		}
+	}
+
+	resized := ctx.scrWidth != ctx.behaviorWidth || ctx.scrHeight != ctx.behaviorHeight
+	if resized && ctx.scrWidth > 0 && ctx.scrHeight > 0 {
+		// the first size is not a change
+		if ctx.behaviorWidth != 0 || ctx.behaviorHeight != 0 {
+			for _, ref := range active {
+				if handler, ok := ref.slot.behavior.(ResizeHandler); ok {
+					bctx := ctx.behaviorContext(ref.object)
+					handler.OnResize(&bctx, ctx.scrWidth, ctx.scrHeight)
+				}
+			}
+		}
+		ctx.behaviorWidth, ctx.behaviorHeight = ctx.scrWidth, ctx.scrHeight
+	}
+
+	for ctx.clock.stepFixed() {
+		for _, ref := range active {
+			if handler, ok := ref.slot.behavior.(FixedUpdateHandler); ok {
+				bctx := ctx.fixedBehaviorContext(ref.object)
+				handler.FixedUpdate(&bctx)
+			}
+		}
+	}
+
+	for _, ref := range active {
+		bctx := ctx.behaviorContext(ref.object)
+		ref.slot.behavior.Update(&bctx)
+	}
+
+	for _, ref := range active {
+		if handler, ok := ref.slot.behavior.(LateUpdateHandler); ok {
+			bctx := ctx.behaviorContext(ref.object)
+			handler.LateUpdate(&bctx)
+		}
+	}
+}
+
+// notifyActive calls OnEnable or OnDisable of the behavior if the active
+// state of its object differs from the one last reported to it.
+func (ctx *Context) notifyActive(object *Object, slot *behaviorSlot) {
+	isActive := object.ActiveInHierarchy()
+	if isActive == slot.activeNotified {
+		return
+	}
+	slot.activeNotified = isActive
+	bctx := ctx.behaviorContext(object)
+	if handler, ok := slot.behavior.(EnableHandler); ok && isActive {
+		handler.OnEnable(&bctx)
+	}
+	if handler, ok := slot.behavior.(DisableHandler); ok && !isActive {
+		handler.OnDisable(&bctx)
+	}
+}
+
+func (ctx *Context) destroyObjects() {
+	for _, obj := range ctx.currentScene.takeDestroyed() {
+		for _, slot := range obj.behaviors {
+			if handler, ok := slot.behavior.(DestroyHandler); ok {
+				bctx := ctx.behaviorContext(obj)
+				handler.OnDestroy(&bctx)
+			}
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package wengine
+
+import (
+	"reflect"
+	"testing"
+)
+
+// logBehavior appends the calls it gets to a shared log.
+type logBehavior struct {
+	name string
+	log  *[]string
+}
+
+func (b *logBehavior) Start(bctx *BehaviorContext)     { *b.log = append(*b.log, b.name+".Start") }
+func (b *logBehavior) Update(bctx *BehaviorContext)    { *b.log = append(*b.log, b.name+".Update") }
+func (b *logBehavior) OnEnable(bctx *BehaviorContext)  { *b.log = append(*b.log, b.name+".OnEnable") }
+func (b *logBehavior) OnDisable(bctx *BehaviorContext) { *b.log = append(*b.log, b.name+".OnDisable") }
+func (b *logBehavior) OnDestroy(bctx *BehaviorContext) { *b.log = append(*b.log, b.name+".OnDestroy") }
+
+// newLogScene applies a scene with a parent and a child object, both
+// enabled, each with a logBehavior, and steps it once.
+func newLogScene(t *testing.T) (ctx *Context, parent, child *Object, log *[]string) {
+	log = &[]string{}
+	ctx = NewHeadlessContext()
+	scene := NewScene()
+	parent, child = NewObject(), NewObject()
+	parent.AddBehavior(&logBehavior{name: "parent", log: log})
+	child.AddBehavior(&logBehavior{name: "child", log: log})
+	child.SetParent(parent, false)
+	parent.SetEnabled(true)
+	child.SetEnabled(true)
+	scene.RegisterObject("parent", parent)
+	scene.RegisterObject("parent/child", child)
+	ctx.RegisterScene("scene", scene)
+	ctx.ApplyScene("scene")
+	if err := ctx.Step(1.0 / 60); err != nil {
+		t.Fatal(err)
+	}
+	*log = (*log)[:0]
+	return
+}
+
+func TestActiveNotifications(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(parent, child *Object)
+		want   []string
+	}{
+		{"disable parent", func(parent, child *Object) {
+			parent.SetEnabled(false)
+		}, []string{"parent.OnDisable", "child.OnDisable"}},
+		{"disable child", func(parent, child *Object) {
+			child.SetEnabled(false)
+		}, []string{"child.OnDisable"}},
+		{"disable and enable", func(parent, child *Object) {
+			parent.SetEnabled(false)
+			parent.SetEnabled(true)
+		}, []string{"parent.OnDisable", "child.OnDisable", "parent.OnEnable", "child.OnEnable"}},
+		{"disable child under disabled parent", func(parent, child *Object) {
+			parent.SetEnabled(false)
+			child.SetEnabled(false)
+			parent.SetEnabled(true)
+		}, []string{"parent.OnDisable", "child.OnDisable", "parent.OnEnable"}},
+		{"detach from disabled parent", func(parent, child *Object) {
+			parent.SetEnabled(false)
+			child.Detach()
+		}, []string{"parent.OnDisable", "child.OnDisable", "child.OnEnable"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, parent, child, log := newLogScene(t)
+			test.change(parent, child)
+			if !reflect.DeepEqual(*log, test.want) {
+				t.Errorf("calls = %v, want %v", *log, test.want)
+			}
+		})
+	}
+}
+
+func TestActiveInHierarchyCached(t *testing.T) {
+	root, child, grandchild := newChain()
+	if grandchild.ActiveInHierarchy() {
+		t.Fatal("active while disabled")
+	}
+	root.SetEnabled(true)
+	child.SetEnabled(true)
+	grandchild.SetEnabled(true)
+	if !grandchild.ActiveInHierarchy() {
+		t.Fatal("not active while all enabled")
+	}
+	child.SetEnabled(false)
+	if grandchild.ActiveInHierarchy() || !root.ActiveInHierarchy() {
+		t.Fatal("disabling the child did not deactivate only its subtree")
+	}
+	grandchild.SetParent(root, false)
+	if !grandchild.ActiveInHierarchy() {
+		t.Fatal("not active after moving under an active parent")
+	}
+}
+
+// stepLogBehavior logs its FixedUpdate, Update and LateUpdate calls.
+type stepLogBehavior struct {
+	name string
+	log  *[]string
+}
+
+func (b *stepLogBehavior) Start(bctx *BehaviorContext)  {}
+func (b *stepLogBehavior) Update(bctx *BehaviorContext) { *b.log = append(*b.log, b.name+".Update") }
+func (b *stepLogBehavior) LateUpdate(bctx *BehaviorContext) {
+	*b.log = append(*b.log, b.name+".LateUpdate")
+}
+func (b *stepLogBehavior) FixedUpdate(bctx *BehaviorContext) {
+	*b.log = append(*b.log, b.name+".FixedUpdate")
+}
+
+// otherStepLogBehavior is a stepLogBehavior of another type, to be given
+// another execution order.
+type otherStepLogBehavior struct {
+	stepLogBehavior
+}
+
+// orderedStepLogBehavior is a stepLogBehavior with its own execution order.
+type orderedStepLogBehavior struct {
+	stepLogBehavior
+	order int
+}
+
+func (b *orderedStepLogBehavior) ExecutionOrder() int { return b.order }
+
+func TestExecuteBehaviors(t *testing.T) {
+	type behaviorSetup struct {
+		object string
+		new    func(log *[]string) Behavior
+	}
+	step := func(name string) func(log *[]string) Behavior {
+		return func(log *[]string) Behavior { return &stepLogBehavior{name: name, log: log} }
+	}
+	other := func(name string) func(log *[]string) Behavior {
+		return func(log *[]string) Behavior {
+			return &otherStepLogBehavior{stepLogBehavior{name: name, log: log}}
+		}
+	}
+	ordered := func(name string, order int) func(log *[]string) Behavior {
+		return func(log *[]string) Behavior {
+			return &orderedStepLogBehavior{stepLogBehavior{name: name, log: log}, order}
+		}
+	}
+	tests := []struct {
+		name      string
+		behaviors []behaviorSetup
+		// execution order set for otherStepLogBehavior
+		otherOrder *int
+		deltas     []float64
+		want       []string
+	}{
+		{"object name tie-break", []behaviorSetup{{"b", step("b")}, {"a", step("a")}, {"c", step("c")}}, nil, []float64{0.125},
+			[]string{"a.Update", "b.Update", "c.Update", "a.LateUpdate", "b.LateUpdate", "c.LateUpdate"}},
+		{"SetExecutionOrder", []behaviorSetup{{"a", step("a")}, {"b", other("b")}}, intPtr(-1), []float64{0.125},
+			[]string{"b.Update", "a.Update", "b.LateUpdate", "a.LateUpdate"}},
+		{"SetExecutionOrder after", []behaviorSetup{{"b", other("b")}, {"a", step("a")}}, intPtr(1), []float64{0.125},
+			[]string{"a.Update", "b.Update", "a.LateUpdate", "b.LateUpdate"}},
+		{"ExecutionOrderer", []behaviorSetup{{"a", ordered("a", 2)}, {"b", ordered("b", -2)}, {"c", step("c")}}, nil, []float64{0.125},
+			[]string{"b.Update", "c.Update", "a.Update", "b.LateUpdate", "c.LateUpdate", "a.LateUpdate"}},
+		{"several on one object", []behaviorSetup{{"a", step("a1")}, {"a", step("a2")}, {"b", step("b")}}, nil, []float64{0.125},
+			[]string{"a1.Update", "a2.Update", "b.Update", "a1.LateUpdate", "a2.LateUpdate", "b.LateUpdate"}},
+		{"several on one object by order", []behaviorSetup{{"a", step("a1")}, {"a", other("a2")}}, intPtr(-1), []float64{0.125},
+			[]string{"a2.Update", "a1.Update", "a2.LateUpdate", "a1.LateUpdate"}},
+		{"fixed steps", []behaviorSetup{{"b", step("b")}, {"a", step("a")}}, nil, []float64{0.125, 0.5, 0.125},
+			[]string{
+				"a.Update", "b.Update", "a.LateUpdate", "b.LateUpdate",
+				"a.FixedUpdate", "b.FixedUpdate", "a.FixedUpdate", "b.FixedUpdate", "a.Update", "b.Update", "a.LateUpdate", "b.LateUpdate",
+				"a.FixedUpdate", "b.FixedUpdate", "a.Update", "b.Update", "a.LateUpdate", "b.LateUpdate",
+			}},
+		{"fixed steps capped", []behaviorSetup{{"a", step("a")}}, nil, []float64{2},
+			[]string{"a.FixedUpdate", "a.FixedUpdate", "a.FixedUpdate", "a.Update", "a.LateUpdate"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			log := &[]string{}
+			ctx := NewContext("null")
+			ctx.SetFixedTimeStep(0.25)
+			ctx.SetMaxFixedSteps(3)
+			if test.otherOrder != nil {
+				ctx.SetExecutionOrder(&otherStepLogBehavior{}, *test.otherOrder)
+			}
+			scene := NewScene()
+			for _, setup := range test.behaviors {
+				object, exists := scene.Objects()[setup.object]
+				if !exists {
+					object = NewObject()
+					object.SetEnabled(true)
+					scene.RegisterObject(setup.object, object)
+				}
+				object.AddBehavior(setup.new(log))
+			}
+			ctx.RegisterScene("scene", scene)
+			ctx.ApplyScene("scene")
+			for _, delta := range test.deltas {
+				if err := ctx.Step(delta); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if n := ctx.Renderer().(*NullRenderer).FrameCount(); n != len(test.deltas) {
+				t.Errorf("%d frames rendered, want %d", n, len(test.deltas))
+			}
+			if !reflect.DeepEqual(*log, test.want) {
+				t.Errorf("calls = %v, want %v", *log, test.want)
+			}
+		})
+	}
+}
+
+func intPtr(v int) *int {
+	return &v
+}
@@ -0,0 +1,235 @@
+package wengine
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type captureRequest struct {
+	camera *CameraComponent
+	async  bool
+	done   func(img *image.RGBA, err error)
+}
+
+// frameSequence writes every rendered frame as a numbered PNG file in dir,
+// and as raw pixels to output, either being optional. Frames are captured
+// asynchronously and written off the render thread, in order.
+type frameSequence struct {
+	dir    string
+	output io.Writer
+	next   int
+	frames chan capturedFrame
+	done   chan struct{}
+	err    error
+}
+
+type capturedFrame struct {
+	path string
+	img  *image.RGBA
+	err  error
+}
+
+var errCannotCapture = errors.New("renderer cannot capture")
+
+// Capture reads back what the last render drew, for camera or for the whole
+// screen if camera is nil. It is meant for contexts advanced with Step; an
+// App presents the frame right after rendering it, use CaptureFrame there.
+func (ctx *Context) Capture(camera *CameraComponent) (*image.RGBA, error) {
+	renderer, ok := ctx.renderer.(CapturingRenderer)
+	if !ok {
+		return nil, errCannotCapture
+	}
+	return renderer.Capture(camera)
+}
+
+// CaptureFrame captures the next rendered frame, for camera or for the whole
+// screen if camera is nil. done is called right after the render, which
+// waits for the GPU to finish the frame.
+func (ctx *Context) CaptureFrame(camera *CameraComponent, done func(img *image.RGBA, err error)) {
+	ctx.captureRequests = append(ctx.captureRequests, captureRequest{camera: camera, done: done})
+}
+
+// CaptureFrameAsync is CaptureFrame without waiting for the GPU: the pixels
+// are read back in the background and done is called by a later frame.
+func (ctx *Context) CaptureFrameAsync(camera *CameraComponent, done func(img *image.RGBA, err error)) {
+	ctx.captureRequests = append(ctx.captureRequests, captureRequest{camera: camera, async: true, done: done})
+}
+
+// Screenshot writes the next rendered frame to a PNG file at path. The PNG
+// is encoded on another goroutine, which calls done with the result if done
+// is not nil.
+func (ctx *Context) Screenshot(path string, done func(err error)) {
+	if done == nil {
+		done = func(err error) {}
+	}
+	ctx.CaptureFrameAsync(nil, func(img *image.RGBA, err error) {
+		go func() {
+			if err == nil {
+				err = SavePNG(path, img)
+			}
+			done(err)
+		}()
+	})
+}
+
+// SetScreenshotKey makes key take a screenshot into dir, the working
+// directory if empty, named after the time and frame it was taken at. done,
+// if not nil, is called with the path of every screenshot and the error
+// writing it, from another goroutine. An empty key disables it.
+func (ctx *Context) SetScreenshotKey(key string, dir string, done func(path string, err error)) {
+	ctx.screenshotKey = key
+	ctx.screenshotDir = dir
+	ctx.screenshotDone = done
+}
+
+// StartFrameCapture writes every following rendered frame to dir, as
+// frame-000000.png, frame-000001.png and so on, until StopFrameCapture is
+// called.
+func (ctx *Context) StartFrameCapture(dir string) error {
+	return ctx.startFrameSequence(dir, nil)
+}
+
+// StartFrameStream writes every following rendered frame to w as raw 8-bit
+// RGBA pixels, top row first, until StopFrameCapture is called.
+func (ctx *Context) StartFrameStream(w io.Writer) error {
+	return ctx.startFrameSequence("", w)
+}
+
+func (ctx *Context) startFrameSequence(dir string, output io.Writer) error {
+	if ctx.frameSequence != nil {
+		return errors.New("already capturing frames")
+	}
+	if _, ok := ctx.renderer.(CapturingRenderer); !ok {
+		return errCannotCapture
+	}
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	sequence := &frameSequence{
+		dir:    dir,
+		output: output,
+		frames: make(chan capturedFrame, 8),
+		done:   make(chan struct{}),
+	}
+	go sequence.write()
+	ctx.frameSequence = sequence
+	return nil
+}
+
+// StopFrameCapture waits for the frames in flight to be written, and
+// returns the first error met by the capture.
+func (ctx *Context) StopFrameCapture() error {
+	sequence := ctx.frameSequence
+	if sequence == nil {
+		return nil
+	}
+	err := ctx.renderer.(CapturingRenderer).FinishCaptures()
+	ctx.frameSequence = nil
+	close(sequence.frames)
+	<-sequence.done
+	if err == nil {
+		err = sequence.err
+	}
+	return err
+}
+
+func (ctx *Context) CapturingFrames() bool {
+	return ctx.frameSequence != nil
+}
+
+// captureFrame serves the capture requests of the frame. It is called right
+// after the frame was rendered, before it is presented.
+func (ctx *Context) captureFrame() {
+	requests := ctx.captureRequests
+	ctx.captureRequests = nil
+	if sequence := ctx.frameSequence; sequence != nil {
+		path := ""
+		if sequence.dir != "" {
+			path = filepath.Join(sequence.dir, fmt.Sprintf("frame-%06d.png", sequence.next))
+		}
+		sequence.next++
+		requests = append(requests, captureRequest{async: true, done: func(img *image.RGBA, err error) {
+			sequence.frames <- capturedFrame{path: path, img: img, err: err}
+		}})
+	}
+	if len(requests) == 0 {
+		return
+	}
+
+	renderer, ok := ctx.renderer.(CapturingRenderer)
+	for _, request := range requests {
+		if !ok {
+			request.done(nil, errCannotCapture)
+			continue
+		}
+		if request.async {
+			if err := renderer.CaptureAsync(request.camera, request.done); err != nil {
+				request.done(nil, err)
+			}
+			continue
+		}
+		img, err := renderer.Capture(request.camera)
+		request.done(img, err)
+	}
+}
+
+// checkScreenshotKey takes a screenshot when the screenshot key went down in
+// the frame.
+func (ctx *Context) checkScreenshotKey() {
+	if ctx.screenshotKey == "" || !ctx.input.GetKeyDown(ctx.screenshotKey) {
+		return
+	}
+	dir := ctx.screenshotDir
+	if dir == "" {
+		dir = "."
+	}
+	name := fmt.Sprintf("screenshot-%s-%d.png", time.Now().Format("20060102-150405"), ctx.FrameCount())
+	path := filepath.Join(dir, name)
+	done := func(err error) {
+		if ctx.screenshotDone != nil {
+			ctx.screenshotDone(path, err)
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		go done(err)
+		return
+	}
+	ctx.Screenshot(path, done)
+}
+
+func (s *frameSequence) write() {
+	defer close(s.done)
+	for frame := range s.frames {
+		err := frame.err
+		if err == nil && frame.path != "" {
+			err = SavePNG(frame.path, frame.img)
+		}
+		if err == nil && s.output != nil {
+			_, err = s.output.Write(frame.img.Pix)
+		}
+		if err != nil && s.err == nil {
+			s.err = err
+		}
+	}
+}
+
+// SavePNG writes img to a PNG file at path.
+func SavePNG(path string, img image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -7,8 +7,13 @@ const (
 	COMPO_LIGHT
 	COMPO_MESH
 	COMPO_SPRITE
+
+	// COMPO_USER is the first type handed out by NewComponentType.
+	COMPO_USER = 1024
 )
 
+// Component is implemented by embedding ComponentBase, which also lets
+// packages other than wengine define their own components.
 type Component interface {
 	Type() int
 	Object() *Object
@@ -16,18 +21,56 @@ type Component interface {
 	setObject(object *Object)
 }
 
-type componentBase struct {
+type ComponentBase struct {
 	parentObject *Object
 }
 
-func (c *componentBase) setObject(object *Object) {
+func (c *ComponentBase) setObject(object *Object) {
 	c.parentObject = object
 }
 
-func (c *componentBase) Object() *Object {
+func (c *ComponentBase) Object() *Object {
 	return c.parentObject
 }
 
+var (
+	nextComponentType = COMPO_USER
+)
+
+// NewComponentType allocates a type value for a user-defined component, to be
+// returned by its Type method. It is meant to be called from init or
+// package-level variable declarations.
+func NewComponentType() int {
+	t := nextComponentType
+	nextComponentType++
+	return t
+}
+
+// GetComponent returns the first component of the object that is a T, and
+// whether one was found. Components are searched in the order they were
+// attached.
+func GetComponent[T Component](object *Object) (T, bool) {
+	for _, compo := range object.attached {
+		if c, ok := compo.(T); ok {
+			return c, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
+// GetComponents returns all components of the object that are a T, in the
+// order they were attached.
+func GetComponents[T Component](object *Object) []T {
+	result := []T{}
+	for _, compo := range object.attached {
+		if c, ok := compo.(T); ok {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
 const (
 	CAMERA_MODE_PERSPECTIVE = iota
 	CAMERA_MODE_ORTHOGRAPHIC
@@ -43,19 +86,58 @@ type CameraComponent struct {
 
 	ViewportX, ViewportY, ViewportW, ViewportH float32
 
+	// TargetTexture names a RenderTextureAsset to draw into instead of the
+	// screen. The viewport is then relative to the texture.
+	TargetTexture string
+
+	// Exposure scales the light reaching the camera, in stops.
+	Exposure float32
+	// ToneMapping maps the lit colors into the displayable range, one of
+	// TONE_MAPPING_*.
+	ToneMapping int
+	// AutoExposure adds to Exposure the stops bringing the average
+	// luminance of the view to middle gray, adapting at AutoExposureSpeed
+	// stops per second, instantly if 0.
+	//
+	// The opengl renderer applies exposure and tone mapping with deferred
+	// shading only, its forward path fails to render cameras using them.
+	AutoExposure      bool
+	AutoExposureSpeed float32
+
 	// perspective only
 	FOV float32
 
 	// orthographic only
 	Width float32
 
-	componentBase
+	// the adapted auto exposure, in stops
+	autoExposure        float32
+	autoExposureAdapted bool
+
+	ComponentBase
 }
 
 func (CameraComponent) Type() int {
 	return COMPO_CAMERA
 }
 
+func (c *CameraComponent) ViewMatrix() mgl32.Mat4 {
+	obj := c.Object()
+	return mgl32.LookAtV(obj.Position(), obj.Position().Add(obj.Forward()), obj.Up())
+}
+
+// ProjectionMatrix builds the projection of the camera for a screen of the
+// given size, taking the viewport into account for the aspect ratio.
+func (c *CameraComponent) ProjectionMatrix(scrWidth, scrHeight int) mgl32.Mat4 {
+	aspect := (c.ViewportW * float32(scrWidth)) / (c.ViewportH * float32(scrHeight))
+	switch c.Mode {
+	case CAMERA_MODE_ORTHOGRAPHIC:
+		return mgl32.Ortho(-c.Width/2, c.Width/2, -c.Width/aspect/2, c.Width/aspect/2, c.NearPlane, c.FarPlane)
+	default:
+		return mgl32.Perspective(c.FOV, aspect, c.NearPlane, c.FarPlane)
+	}
+}
+
 type MeshComponent struct {
 	Mesh     string
 	Material string
@@ -64,7 +146,7 @@ type MeshComponent struct {
 	CastShadow    bool
 	ReceiveShader bool
 
-	componentBase
+	ComponentBase
 }
 
 func (MeshComponent) Type() int {
@@ -97,7 +179,7 @@ type LightComponent struct {
 	// spot light
 	Angle float32
 
-	componentBase
+	ComponentBase
 }
 
 func (LightComponent) Type() int {
@@ -109,7 +191,7 @@ type SpriteComponent struct {
 	Material string
 	Shader   string
 
-	componentBase
+	ComponentBase
 }
 
 func (SpriteComponent) Type() int {
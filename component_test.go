@@ -0,0 +1,95 @@
+package wengine
+
+import "testing"
+
+var markerComponentType = NewComponentType()
+
+// markerComponent is a user-defined component with a name to tell
+// instances apart.
+type markerComponent struct {
+	Name string
+
+	ComponentBase
+}
+
+func (markerComponent) Type() int {
+	return markerComponentType
+}
+
+func componentNames(compos []Component) []string {
+	names := []string{}
+	for _, compo := range compos {
+		switch c := compo.(type) {
+		case *markerComponent:
+			names = append(names, c.Name)
+		case *LightComponent:
+			names = append(names, "light")
+		case *MeshComponent:
+			names = append(names, "mesh")
+		case *CameraComponent:
+			names = append(names, "camera")
+		}
+	}
+	return names
+}
+
+func TestGetComponentsOrder(t *testing.T) {
+	tests := []struct {
+		name   string
+		attach []Component
+		detach int
+		want   []string
+	}{
+		{"one type", []Component{&markerComponent{Name: "a"}, &markerComponent{Name: "b"}}, -1, []string{"a", "b"}},
+		{"mixed types", []Component{&MeshComponent{}, &markerComponent{Name: "a"}, &LightComponent{}, &CameraComponent{}, &markerComponent{Name: "b"}}, -1, []string{"mesh", "a", "light", "camera", "b"}},
+		{"after detach", []Component{&LightComponent{}, &markerComponent{Name: "a"}, &MeshComponent{}}, 1, []string{"light", "mesh"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// repeated, map order would show up as a flaky result
+			for i := 0; i < 20; i++ {
+				obj := NewObject()
+				for _, compo := range test.attach {
+					obj.AttachComponent(cloneComponent(compo))
+				}
+				if test.detach >= 0 {
+					obj.DetachComponent(obj.attached[test.detach])
+				}
+				got := componentNames(GetComponents[Component](obj))
+				if len(got) != len(test.want) {
+					t.Fatalf("components = %v, want %v", got, test.want)
+				}
+				for j := range got {
+					if got[j] != test.want[j] {
+						t.Fatalf("components = %v, want %v", got, test.want)
+					}
+				}
+				first, ok := GetComponent[Component](obj)
+				if !ok || componentNames([]Component{first})[0] != test.want[0] {
+					t.Fatalf("first component = %v, want %v", first, test.want[0])
+				}
+			}
+		})
+	}
+}
+
+func TestGetComponentByType(t *testing.T) {
+	obj := NewObject()
+	obj.AttachComponent(&LightComponent{Range: 1})
+	obj.AttachComponent(&markerComponent{Name: "a"})
+	obj.AttachComponent(&LightComponent{Range: 2})
+
+	light, ok := GetComponent[*LightComponent](obj)
+	if !ok || light.Range != 1 {
+		t.Errorf("first light = %+v, %v", light, ok)
+	}
+	if lights := GetComponents[*LightComponent](obj); len(lights) != 2 || lights[1].Range != 2 {
+		t.Errorf("lights = %v", lights)
+	}
+	if _, ok := GetComponent[*CameraComponent](obj); ok {
+		t.Error("found a camera")
+	}
+	if compos := obj.ComponentsOfType(markerComponentType); len(compos) != 1 {
+		t.Errorf("markers = %v", compos)
+	}
+}
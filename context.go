@@ -2,6 +2,7 @@ package wengine
 
 import (
 	"errors"
+	"reflect"
 )
 
 type AssetMap map[string]Asset
@@ -9,32 +10,90 @@ type SceneMap map[string]*Scene
 
 type Context struct {
 	scrWidth, scrHeight int
+	// the screen size the renderer targets were made for, and the one
+	// behaviors were last told about
+	rendererWidth, rendererHeight int
+	behaviorWidth, behaviorHeight int
 
 	assets       AssetMap
 	scenes       SceneMap
 	currentScene *Scene
+	// lastScene is the scene of the previous frame
+	lastScene *Scene
 
 	renderer        Renderer
 	rendererName    string
 	rendererSetting RendererSetting
+	rendererReady   bool
 
-	input *Input
+	input    *Input
+	recorder *inputRecorder
+	player   *inputPlayer
+
+	clock clock
+
+	captureRequests []captureRequest
+	frameSequence   *frameSequence
+	screenshotKey   string
+	screenshotDir   string
+	screenshotDone  func(path string, err error)
+
+	executionOrders map[reflect.Type]int
 
 	assetsToFinalize AssetMap
 }
 
-func NewContext() *Context {
-	renderer, exists := registeredRenderers["opengl"]
-	if !exists {
-		return nil
+// NewContext creates a context with the first registered renderer out of
+// rendererNames, falling back to "opengl" and then "null".
+func NewContext(rendererNames ...string) *Context {
+	ctx := NewHeadlessContext()
+	for _, name := range append(rendererNames, defaultRendererNames...) {
+		if factory, exists := registeredRenderers[name]; exists {
+			ctx.renderer = factory()
+			ctx.rendererName = name
+			break
+		}
 	}
-	return &Context{assets: make(AssetMap), scenes: make(SceneMap), renderer: renderer, rendererName: "opengl", input: newInput()}
+	return ctx
+}
+
+// NewHeadlessContext creates a context without a renderer, to be advanced
+// with Step instead of an App.
+func NewHeadlessContext() *Context {
+	return &Context{assets: make(AssetMap), scenes: make(SceneMap), input: newInput(), clock: newClock(), executionOrders: map[reflect.Type]int{}}
 }
 
 func (ctx *Context) Input() *Input {
 	return ctx.input
 }
 
+func (ctx *Context) Renderer() Renderer {
+	return ctx.renderer
+}
+
+func (ctx *Context) RendererName() string {
+	return ctx.rendererName
+}
+
+// initRenderer initializes the renderer once.
+func (ctx *Context) initRenderer() error {
+	if ctx.renderer == nil || ctx.rendererReady {
+		return nil
+	}
+	if err := ctx.renderer.Init(ctx); err != nil {
+		return err
+	}
+	ctx.rendererReady = true
+	ctx.rendererWidth, ctx.rendererHeight = ctx.scrWidth, ctx.scrHeight
+	return nil
+}
+
+// InitRenderer initializes the renderer as the first Step would, to set it
+// up beforehand, such as to make it draw offscreen.
+func (ctx *Context) InitRenderer() error {
+	return ctx.initRenderer()
+}
+
 func (ctx *Context) AccessRenderSetting() *RendererSetting {
 	return &ctx.rendererSetting
 }
@@ -45,6 +104,17 @@ func (ctx *Context) ScreenSize() (width, height int) {
 	return
 }
 
+// TargetSize returns the size of what camera draws into, its render texture
+// or the screen.
+func (ctx *Context) TargetSize(camera *CameraComponent) (width, height int) {
+	if camera.TargetTexture != "" {
+		if texture, ok := ctx.assets[camera.TargetTexture].(*RenderTextureAsset); ok {
+			return texture.Width, texture.Height
+		}
+	}
+	return ctx.ScreenSize()
+}
+
 func (ctx *Context) Assets() AssetMap {
 	return ctx.assets
 }
@@ -67,8 +137,11 @@ func (ctx *Context) ApplyScene(name string) {
 		return
 	}
 	ctx.currentScene = scene
+	scene.context = ctx
 }
 
+// SetScreenSize sets the size of the framebuffer rendered to. A change is
+// handed to the renderer and to behaviors on the next frame.
 func (ctx *Context) SetScreenSize(width, height int) {
 	ctx.scrWidth = width
 	ctx.scrHeight = height
@@ -89,41 +162,22 @@ func (ctx *Context) LoadAssets(assets []string) error {
 		}
 		println("loaded asset: " + name)
 	}
-	ctx.renderer.NotifyInstall(assets)
+	if ctx.renderer != nil {
+		ctx.renderer.NotifyInstall(assets)
+	}
 	return nil
 }
 
 func (ctx *Context) asyncLoadScene(scene *Scene) (chan error, error) {
-	// figure out all assets
-	assetsToLoad := []string{}
+	objects := make([]*Object, 0, len(scene.objects))
 	for _, obj := range scene.objects {
-		for _, compo := range obj.components {
-			switch compo.Type() {
-			case COMPO_MESH:
-				meshCompo, ok := compo.(*MeshComponent)
-				if !ok {
-					return nil, errors.New("found invalid component")
-				}
-
-				meshAsset := ctx.assets[meshCompo.Mesh]
-				if meshAsset != nil {
-					assetsToLoad = append(assetsToLoad, meshCompo.Mesh)
-				} else {
-					return nil, errors.New("found invalid component")
-				}
-
-				materialAsset := ctx.assets[meshCompo.Material]
-				if materialAsset != nil {
-					assetsToLoad = append(assetsToLoad, meshCompo.Material)
-				}
-
-				shaderAsset := ctx.assets[meshCompo.Shader]
-				if shaderAsset != nil {
-					assetsToLoad = append(assetsToLoad, meshCompo.Shader)
-				}
-			}
-		}
+		objects = append(objects, obj)
+	}
+	assetsToLoad, err := ctx.objectAssets(objects)
+	if err != nil {
+		return nil, err
 	}
+	scene.toLoad = nil
 	result := make(chan error, 1)
 	go func() {
 		err := ctx.LoadAssets(assetsToLoad)
@@ -131,3 +185,158 @@ func (ctx *Context) asyncLoadScene(scene *Scene) (chan error, error) {
 	}()
 	return result, nil
 }
+
+// objectAssets figures out the assets the meshes of objects need.
+func (ctx *Context) objectAssets(objects []*Object) ([]string, error) {
+	assetsToLoad := []string{}
+	for _, obj := range objects {
+		for _, compo := range obj.components[COMPO_MESH] {
+			meshCompo, ok := compo.(*MeshComponent)
+			if !ok {
+				return nil, errors.New("found invalid component")
+			}
+
+			meshAsset := ctx.assets[meshCompo.Mesh]
+			if meshAsset != nil {
+				assetsToLoad = append(assetsToLoad, meshCompo.Mesh)
+			} else {
+				return nil, errors.New("found invalid component")
+			}
+
+			materialAsset := ctx.assets[meshCompo.Material]
+			if materialAsset != nil {
+				assetsToLoad = append(assetsToLoad, meshCompo.Material)
+			}
+
+			shaderAsset := ctx.assets[meshCompo.Shader]
+			if shaderAsset != nil {
+				assetsToLoad = append(assetsToLoad, meshCompo.Shader)
+			}
+		}
+	}
+	return assetsToLoad, nil
+}
+
+// loadInstantiated loads the assets of the objects instantiated in the
+// current scene since the last frame.
+func (ctx *Context) loadInstantiated() error {
+	scene := ctx.currentScene
+	if len(scene.toLoad) == 0 {
+		return nil
+	}
+	objects := []*Object{}
+	for _, object := range scene.toLoad {
+		if object.destroyed {
+			continue
+		}
+		objects = append(objects, object)
+	}
+	scene.toLoad = nil
+	assets, err := ctx.objectAssets(objects)
+	if err != nil {
+		return err
+	}
+	if len(assets) == 0 {
+		return nil
+	}
+	return ctx.LoadAssets(assets)
+}
+
+// Step advances the context by one frame of deltaTime seconds without a
+// window: the applied scene is loaded and started if it changed, transforms
+// are updated, the scene is rendered if there is a renderer, and behaviors
+// run with the input state injected since the last step.
+func (ctx *Context) Step(deltaTime float64) error {
+	if err := ctx.initRenderer(); err != nil {
+		return err
+	}
+	if err := ctx.beginFrame(); err != nil {
+		return err
+	}
+	if ctx.renderer != nil {
+		if err := ctx.renderer.Render(ctx.currentScene); err != nil {
+			return err
+		}
+		ctx.captureFrame()
+	}
+	return ctx.endFrame(ctx.clock.unscaledTime+deltaTime, deltaTime)
+}
+
+// beginFrame switches to the applied scene if it changed and prepares the
+// current scene for rendering.
+func (ctx *Context) beginFrame() error {
+	if ctx.currentScene == nil {
+		return errors.New("no scene applied")
+	}
+	if ctx.currentScene != ctx.lastScene {
+		result, err := ctx.asyncLoadScene(ctx.currentScene)
+		if err != nil {
+			return err
+		}
+		if err := <-result; err != nil {
+			return err
+		}
+		ctx.executeBehaviors(true)
+	}
+	ctx.lastScene = ctx.currentScene
+	if err := ctx.loadInstantiated(); err != nil {
+		return err
+	}
+
+	if err := ctx.resizeRenderer(); err != nil {
+		return err
+	}
+
+	ctx.currentScene.updateTransforms()
+	return nil
+}
+
+// resizeRenderer hands a new screen size to the renderer. A zero size, as
+// of a minimized window, is kept back until the window is restored.
+func (ctx *Context) resizeRenderer() error {
+	if !ctx.rendererReady || ctx.scrWidth <= 0 || ctx.scrHeight <= 0 {
+		return nil
+	}
+	if ctx.scrWidth == ctx.rendererWidth && ctx.scrHeight == ctx.rendererHeight {
+		return nil
+	}
+	if renderer, ok := ctx.renderer.(ResizableRenderer); ok {
+		if err := renderer.Resize(ctx.scrWidth, ctx.scrHeight); err != nil {
+			return err
+		}
+	}
+	ctx.rendererWidth, ctx.rendererHeight = ctx.scrWidth, ctx.scrHeight
+	return nil
+}
+
+// endFrame runs the behaviors of the frame, now being the unscaled time of
+// the frame used for input. While replaying, both come from the recording
+// unless only its events are replayed.
+func (ctx *Context) endFrame(now, deltaTime float64) error {
+	if ctx.player != nil {
+		eventsOnly := ctx.player.eventsOnly
+		replayNow, replayDeltaTime, ok, err := ctx.replayFrame()
+		if err != nil {
+			return err
+		}
+		if ok && !eventsOnly {
+			now, deltaTime = replayNow, replayDeltaTime
+		}
+	}
+	ctx.input.frameStart(now)
+	if ctx.recorder != nil {
+		if err := ctx.recorder.recordFrame(now, deltaTime, ctx.input.frameEvents); err != nil {
+			ctx.StopRecording()
+			return err
+		}
+	}
+	ctx.clock.advance(deltaTime)
+	ctx.executeBehaviors(false)
+	ctx.checkScreenshotKey()
+	ctx.input.frameEnd()
+	if ctx.player != nil && ctx.player.ended {
+		ctx.StopReplay()
+	}
+	ctx.destroyObjects()
+	return nil
+}
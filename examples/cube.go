@@ -187,7 +187,7 @@ func setupScene(context *wengine.Context, scene *wengine.Scene) {
 	scene.RegisterObject("simpleCube1", cubeObject1)
 
 	cubeObject2 := wengine.NewObject()
-	cubeObject2.SetParent(cubeObject1)
+	cubeObject2.SetParent(cubeObject1, false)
 	cubeObject2.Translate(mgl32.Vec3{5, 0, 0})
 	cubeObject2.SetBehavior(&RotationBehavior{axis: mgl32.Vec3{1, 1, 1}})
 	cubeObject2.AttachComponent(&wengine.MeshComponent{
@@ -244,7 +244,7 @@ func (b *CameraBehavior) Start(bctx *wengine.BehaviorContext) {
 	b.lastCursorMode = wengine.CURSOR_MODE_NORMAL
 	bctx.Context.Input().SetCursorMode(b.lastCursorMode)
 
-	b.spotLight = bctx.Context.CurrentScene().Objects()["spotLight"].Components()[wengine.COMPO_LIGHT].(*wengine.LightComponent)
+	b.spotLight, _ = wengine.GetComponent[*wengine.LightComponent](bctx.Context.CurrentScene().Objects()["spotLight"])
 }
 
 func (b *CameraBehavior) Update(bctx *wengine.BehaviorContext) {
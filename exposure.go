@@ -0,0 +1,152 @@
+package wengine
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+)
+
+const (
+	// TONE_MAPPING_NONE clamps the lit colors, as renderers without HDR do.
+	TONE_MAPPING_NONE = iota
+	TONE_MAPPING_REINHARD
+	TONE_MAPPING_ACES
+	TONE_MAPPING_FILMIC
+)
+
+const (
+	histogramBins = 64
+	// the luminance range of the histogram, in stops
+	histogramMinStop = -12
+	histogramMaxStop = 12
+	// the part of the histogram auto exposure averages, leaving out the
+	// darkest and the brightest pixels
+	histogramLow  = 0.5
+	histogramHigh = 0.95
+
+	// middleGray is the luminance auto exposure brings the average to
+	middleGray = 0.18
+	// maxAutoExposure limits auto exposure, in stops either way
+	maxAutoExposure = 10
+)
+
+// LuminanceHistogram counts the luminance of linear colors in logarithmic
+// bins, for auto exposure.
+type LuminanceHistogram struct {
+	bins  [histogramBins]int
+	count int
+}
+
+// Add counts the luminance of a linear color.
+func (h *LuminanceHistogram) Add(color mgl32.Vec3) {
+	luminance := 0.2126*color.X() + 0.7152*color.Y() + 0.0722*color.Z()
+	stop := float64(histogramMinStop)
+	if luminance > 0 {
+		stop = math.Log2(float64(luminance))
+	}
+	bin := int((stop - histogramMinStop) / (histogramMaxStop - histogramMinStop) * histogramBins)
+	if bin < 0 {
+		bin = 0
+	}
+	if bin >= histogramBins {
+		bin = histogramBins - 1
+	}
+	h.bins[bin]++
+	h.count++
+}
+
+func (h *LuminanceHistogram) Reset() {
+	*h = LuminanceHistogram{}
+}
+
+// AverageLuminance returns the geometric mean luminance of the counted
+// colors, leaving out the darkest half and the brightest 5%. It is middle
+// gray if nothing was counted.
+func (h *LuminanceHistogram) AverageLuminance() float64 {
+	low, high := histogramLow*float64(h.count), histogramHigh*float64(h.count)
+	sum, weight, seen := 0.0, 0.0, 0.0
+	for i, n := range h.bins {
+		// the part of the bin between low and high
+		from, to := math.Max(seen, low), math.Min(seen+float64(n), high)
+		if to > from {
+			stop := histogramMinStop + (float64(i)+0.5)/histogramBins*(histogramMaxStop-histogramMinStop)
+			sum += stop * (to - from)
+			weight += to - from
+		}
+		seen += float64(n)
+	}
+	if weight == 0 {
+		return middleGray
+	}
+	return math.Exp2(sum / weight)
+}
+
+// AdaptExposure moves the auto exposure of the camera toward the one that
+// brings the average luminance of h to middle gray, by AutoExposureSpeed
+// stops per second over deltaTime. The first call sets it right away.
+func (c *CameraComponent) AdaptExposure(h *LuminanceHistogram, deltaTime float64) {
+	target := float32(math.Log2(middleGray / h.AverageLuminance()))
+	target = mgl32.Clamp(target, -maxAutoExposure, maxAutoExposure)
+	if !c.autoExposureAdapted || c.AutoExposureSpeed <= 0 {
+		c.autoExposure = target
+		c.autoExposureAdapted = true
+		return
+	}
+	step := c.AutoExposureSpeed * float32(deltaTime)
+	if target > c.autoExposure {
+		c.autoExposure = float32(math.Min(float64(c.autoExposure+step), float64(target)))
+	} else {
+		c.autoExposure = float32(math.Max(float64(c.autoExposure-step), float64(target)))
+	}
+}
+
+// TotalExposure returns the exposure applied to what the camera sees, in
+// stops: Exposure, plus the adapted auto exposure if enabled.
+func (c *CameraComponent) TotalExposure() float32 {
+	if !c.AutoExposure {
+		return c.Exposure
+	}
+	return c.Exposure + c.autoExposure
+}
+
+// ToneMap scales a linear color by exposure stops and maps it into [0, 1]
+// with a TONE_MAPPING_* operator. Renderers implementing it in shaders
+// follow this one.
+func ToneMap(color mgl32.Vec3, exposure float32, mode int) mgl32.Vec3 {
+	color = color.Mul(float32(math.Exp2(float64(exposure))))
+	for i, v := range color {
+		switch mode {
+		case TONE_MAPPING_REINHARD:
+			v = v / (1 + v)
+		case TONE_MAPPING_ACES:
+			// Narkowicz's fit of the ACES curve
+			v = (v * (2.51*v + 0.03)) / (v*(2.43*v+0.59) + 0.14)
+		case TONE_MAPPING_FILMIC:
+			// Hable's curve, white point 11.2
+			v = hable(v*2) / hable(11.2)
+		}
+		color[i] = mgl32.Clamp(v, 0, 1)
+	}
+	return color
+}
+
+func hable(x float32) float32 {
+	const a, b, c, d, e, f = 0.15, 0.50, 0.10, 0.20, 0.02, 0.30
+	return (x*(a*x+c*b)+d*e)/(x*(a*x+b)+d*f) - e/f
+}
+
+// LinearToSRGB encodes a linear value in [0, 1] for display.
+func LinearToSRGB(v float32) float32 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*float32(math.Pow(float64(v), 1/2.4)) - 0.055
+}
+
+// SRGBToLinear decodes a value of a color picked on screen, such as a
+// texture or a DiffuseColor.
+func SRGBToLinear(v float32) float32 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return float32(math.Pow(float64((v+0.055)/1.055), 2.4))
+}
@@ -0,0 +1,129 @@
+package wengine
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+	"testing"
+)
+
+func TestToneMap(t *testing.T) {
+	tests := []struct {
+		name     string
+		color    mgl32.Vec3
+		exposure float32
+		mode     int
+		want     mgl32.Vec3
+	}{
+		{"none clamps", mgl32.Vec3{0.5, 2, -1}, 0, TONE_MAPPING_NONE, mgl32.Vec3{0.5, 1, 0}},
+		{"none with exposure", mgl32.Vec3{0.25, 0.1, 0}, 1, TONE_MAPPING_NONE, mgl32.Vec3{0.5, 0.2, 0}},
+		{"none with negative exposure", mgl32.Vec3{1, 1, 1}, -2, TONE_MAPPING_NONE, mgl32.Vec3{0.25, 0.25, 0.25}},
+		{"reinhard", mgl32.Vec3{1, 3, 0}, 0, TONE_MAPPING_REINHARD, mgl32.Vec3{0.5, 0.75, 0}},
+		{"reinhard with exposure", mgl32.Vec3{0.5, 0, 0}, 1, TONE_MAPPING_REINHARD, mgl32.Vec3{0.5, 0, 0}},
+		{"aces black", mgl32.Vec3{0, 0, 0}, 0, TONE_MAPPING_ACES, mgl32.Vec3{0, 0, 0}},
+		{"aces white", mgl32.Vec3{100, 100, 100}, 0, TONE_MAPPING_ACES, mgl32.Vec3{1, 1, 1}},
+		{"aces mid", mgl32.Vec3{0.18, 0.18, 0.18}, 0, TONE_MAPPING_ACES, mgl32.Vec3{0.2669, 0.2669, 0.2669}},
+		{"filmic black", mgl32.Vec3{0, 0, 0}, 0, TONE_MAPPING_FILMIC, mgl32.Vec3{0, 0, 0}},
+		{"filmic white point", mgl32.Vec3{5.6, 5.6, 5.6}, 0, TONE_MAPPING_FILMIC, mgl32.Vec3{1, 1, 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := ToneMap(test.color, test.exposure, test.mode)
+			if got.Sub(test.want).Len() > 1e-3 {
+				t.Errorf("ToneMap(%v, %v) = %v, want %v", test.color, test.exposure, got, test.want)
+			}
+		})
+	}
+}
+
+func TestToneMapMonotonic(t *testing.T) {
+	for _, mode := range []int{TONE_MAPPING_NONE, TONE_MAPPING_REINHARD, TONE_MAPPING_ACES, TONE_MAPPING_FILMIC} {
+		last := float32(-1)
+		for v := float32(0); v < 20; v += 0.05 {
+			got := ToneMap(mgl32.Vec3{v, v, v}, 0, mode).X()
+			if got < last || got < 0 || got > 1 {
+				t.Fatalf("mode %d: ToneMap(%v) = %v after %v", mode, v, got, last)
+			}
+			last = got
+		}
+	}
+}
+
+func TestLuminanceHistogram(t *testing.T) {
+	gray := func(v float32) mgl32.Vec3 { return mgl32.Vec3{v, v, v} }
+	tests := []struct {
+		name   string
+		colors []mgl32.Vec3
+		count  int
+		want   float64
+	}{
+		{"empty is middle gray", nil, 0, middleGray},
+		{"uniform", []mgl32.Vec3{gray(1)}, 100, 1},
+		{"uniform dark", []mgl32.Vec3{gray(1.0 / 16)}, 100, 1.0 / 16},
+		{"darkest half left out", []mgl32.Vec3{gray(1.0 / 256), gray(4)}, 50, 4},
+		{"black half left out", []mgl32.Vec3{gray(0), gray(2)}, 25, 2},
+		{"brightest part left out", []mgl32.Vec3{gray(1), gray(1), gray(1), gray(1), gray(1), gray(1), gray(1), gray(1), gray(1), gray(1), gray(1), gray(1), gray(1), gray(1), gray(1), gray(1), gray(1), gray(1), gray(1), gray(1024)}, 5, 1},
+		{"clamped to the range", []mgl32.Vec3{gray(1 << 20)}, 10, math.Exp2(histogramMaxStop - 0.5*(histogramMaxStop-histogramMinStop)/histogramBins)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			histogram := LuminanceHistogram{}
+			for i := 0; i < test.count; i++ {
+				for _, color := range test.colors {
+					histogram.Add(color)
+				}
+			}
+			got := histogram.AverageLuminance()
+			// bins are 3/8 of a stop wide
+			if stops := math.Abs(math.Log2(got / test.want)); stops > 0.2 {
+				t.Errorf("AverageLuminance() = %v, want %v", got, test.want)
+			}
+			histogram.Reset()
+			if histogram.AverageLuminance() != middleGray {
+				t.Error("not empty after Reset")
+			}
+		})
+	}
+}
+
+func TestAdaptExposure(t *testing.T) {
+	bright := LuminanceHistogram{}
+	for i := 0; i < 10; i++ {
+		bright.Add(mgl32.Vec3{middleGray * 4, middleGray * 4, middleGray * 4})
+	}
+	tests := []struct {
+		name   string
+		speed  float32
+		deltas []float64
+		want   float32
+	}{
+		{"first call sets it", 1, []float64{0.1}, -2},
+		{"no speed sets it", 0, []float64{0.1, 0.1}, -2},
+		{"stops at the target", 10, []float64{0, 1}, -2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			camera := &CameraComponent{AutoExposure: true, AutoExposureSpeed: test.speed}
+			for _, delta := range test.deltas {
+				camera.AdaptExposure(&bright, delta)
+			}
+			if got := camera.TotalExposure(); math.Abs(float64(got-test.want)) > 0.2 {
+				t.Errorf("exposure = %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	// from a dark frame to a bright one, moving by speed stops per second
+	dark := LuminanceHistogram{}
+	dark.Add(mgl32.Vec3{middleGray / 4, middleGray / 4, middleGray / 4})
+	camera := &CameraComponent{AutoExposure: true, AutoExposureSpeed: 1, Exposure: 1}
+	camera.AdaptExposure(&dark, 0)
+	start := camera.TotalExposure()
+	camera.AdaptExposure(&bright, 0.5)
+	if got := camera.TotalExposure(); math.Abs(float64(start-got-0.5)) > 1e-4 {
+		t.Errorf("exposure moved from %v to %v, want by 0.5", start, got)
+	}
+	camera.AutoExposure = false
+	if camera.TotalExposure() != 1 {
+		t.Errorf("manual exposure = %v, want 1", camera.TotalExposure())
+	}
+}
@@ -3,14 +3,19 @@ package wengine
 import (
 	"errors"
 	"fmt"
-	"github.com/go-gl/glfw/v3.2/glfw"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"image"
 	"runtime"
+	"sort"
 	"time"
 )
 
 const (
 	WINDOW_MODE_WINDOWED = iota
+	// exclusive full screen, changing the video mode of the monitor
 	WINDOW_MODE_FULL_SCREEN
+	// full screen at the current video mode of the monitor
+	WINDOW_MODE_BORDERLESS
 )
 
 var keyMap = map[int]string{
@@ -147,12 +152,45 @@ var keyMap = map[int]string{
 }
 
 type Config struct {
-	Context       *Context
+	Context *Context
+	// Renderer is the name of the renderer of the context created when
+	// Context is nil. See NewContext for the fallback.
+	Renderer string
+
 	Width, Height int
 	WindowMode    int
 	WindowTitle   string
 	FrameLimit    int
 	VSync         bool
+	Resizable     bool
+
+	// Monitor is the index in App.Monitors of the monitor to go full screen
+	// on, the primary one if out of range.
+	Monitor int
+	// RefreshRate is the refresh rate of the exclusive full screen mode,
+	// the highest available if unset.
+	RefreshRate int
+
+	// FixedTimeStep is the interval of FixedUpdate in seconds, 0.02 if unset.
+	FixedTimeStep float64
+	// MaxFixedSteps limits the fixed steps run in one frame, 8 if unset.
+	MaxFixedSteps int
+
+	// RecordInput is a file to record the input of the run to.
+	RecordInput string
+	// ReplayInput is a recorded file to play the input of the run back from.
+	ReplayInput string
+
+	// ScreenshotKey is a key taking a screenshot into ScreenshotDir, the
+	// working directory if unset. ScreenshotDone, if set, is called with
+	// the path of every screenshot and the error writing it.
+	ScreenshotKey  string
+	ScreenshotDir  string
+	ScreenshotDone func(path string, err error)
+
+	// Offline, if set, renders frames offscreen at a fixed frame delta
+	// instead of running in a window.
+	Offline *OfflineConfig
 }
 
 type App struct {
@@ -163,8 +201,24 @@ type App struct {
 	title         string
 	frameLimit    int
 	vSync         bool
+	resizable     bool
+
+	monitor     int
+	refreshRate int
+	// the window placement to go back to when leaving full screen, the
+	// position being unknown until windowedPlaced
+	windowedX, windowedY          int
+	windowedWidth, windowedHeight int
+	windowedPlaced                bool
+	// lastFullScreenMode is the mode ToggleFullScreen switches to
+	lastFullScreenMode int
+	icon               []image.Image
+
+	recordInput, replayInput string
 
-	lastScene *Scene
+	offline *OfflineConfig
+
+	joysticks map[glfw.Joystick]*polledJoystick
 
 	currentTime float64
 	lastTime    float64
@@ -174,16 +228,32 @@ type App struct {
 
 func NewApp(config *Config) (*App, error) {
 	if config.Context == nil {
-		config.Context = NewContext()
+		config.Context = NewContext(config.Renderer)
+	}
+	if config.FixedTimeStep > 0 {
+		config.Context.SetFixedTimeStep(config.FixedTimeStep)
+	}
+	if config.MaxFixedSteps > 0 {
+		config.Context.SetMaxFixedSteps(config.MaxFixedSteps)
+	}
+	if config.ScreenshotKey != "" {
+		config.Context.SetScreenshotKey(config.ScreenshotKey, config.ScreenshotDir, config.ScreenshotDone)
 	}
 	return &App{
-		width:      config.Width,
-		height:     config.Height,
-		title:      config.WindowTitle,
-		winMode:    config.WindowMode,
-		frameLimit: config.FrameLimit,
-		vSync:      config.VSync,
-		context:    config.Context,
+		width:       config.Width,
+		height:      config.Height,
+		title:       config.WindowTitle,
+		winMode:     config.WindowMode,
+		frameLimit:  config.FrameLimit,
+		vSync:       config.VSync,
+		resizable:   config.Resizable,
+		monitor:     config.Monitor,
+		refreshRate: config.RefreshRate,
+		recordInput: config.RecordInput,
+		replayInput: config.ReplayInput,
+		offline:     config.Offline,
+		context:     config.Context,
+		joysticks:   map[glfw.Joystick]*polledJoystick{},
 	}, nil
 }
 
@@ -202,18 +272,39 @@ func (a *App) Run() error {
 	}
 	defer glfw.Terminate()
 
-	glfw.WindowHint(glfw.Resizable, glfw.False)
+	if a.resizable {
+		glfw.WindowHint(glfw.Resizable, glfw.True)
+	} else {
+		glfw.WindowHint(glfw.Resizable, glfw.False)
+	}
 	glfw.WindowHint(glfw.ContextVersionMajor, 3)
 	glfw.WindowHint(glfw.ContextVersionMinor, 2)
 	glfw.WindowHint(glfw.OpenGLProfile, glfw.OpenGLCoreProfile)
 	glfw.WindowHint(glfw.OpenGLForwardCompatible, glfw.True)
 
+	if a.offline != nil {
+		return a.runOffline()
+	}
+
+	a.windowedWidth, a.windowedHeight = a.width, a.height
 	switch a.winMode {
 	case WINDOW_MODE_WINDOWED:
 		a.window, err = glfw.CreateWindow(a.width, a.height, a.title, nil, nil)
 		break
 	case WINDOW_MODE_FULL_SCREEN:
-		a.window, err = glfw.CreateWindow(a.width, a.height, a.title, glfw.GetPrimaryMonitor(), nil)
+		if a.refreshRate > 0 {
+			glfw.WindowHint(glfw.RefreshRate, a.refreshRate)
+		}
+		a.window, err = glfw.CreateWindow(a.width, a.height, a.title, a.glfwMonitor(), nil)
+		break
+	case WINDOW_MODE_BORDERLESS:
+		monitor := a.glfwMonitor()
+		mode := monitor.GetVideoMode()
+		glfw.WindowHint(glfw.RedBits, mode.RedBits)
+		glfw.WindowHint(glfw.GreenBits, mode.GreenBits)
+		glfw.WindowHint(glfw.BlueBits, mode.BlueBits)
+		glfw.WindowHint(glfw.RefreshRate, mode.RefreshRate)
+		a.window, err = glfw.CreateWindow(mode.Width, mode.Height, a.title, monitor, nil)
 		break
 	default:
 		return errors.New("window uninitialized")
@@ -222,24 +313,34 @@ func (a *App) Run() error {
 		return errors.New(fmt.Sprint("unable to init window:", err))
 	}
 	a.window.MakeContextCurrent()
+	if a.winMode != WINDOW_MODE_WINDOWED {
+		a.lastFullScreenMode = a.winMode
+	}
+	if a.icon != nil {
+		a.window.SetIcon(a.icon)
+	}
 
 	a.window.SetKeyCallback(a.keyCallBack)
 	a.window.SetMouseButtonCallback(a.mouseCallBack)
+	a.window.SetCursorPosCallback(a.cursorPosCallBack)
+	a.window.SetScrollCallback(a.scrollCallBack)
+	a.window.SetCharCallback(a.charCallBack)
+	a.window.SetCursorEnterCallback(a.cursorEnterCallBack)
+	a.window.SetSizeCallback(a.sizeCallBack)
+	a.window.SetFramebufferSizeCallback(a.framebufferSizeCallBack)
+	glfw.SetJoystickCallback(a.joystickCallBack)
+	defer glfw.SetJoystickCallback(nil)
 
 	scrWidth, scrHeight := a.window.GetFramebufferSize()
 	a.context.SetScreenSize(scrWidth, scrHeight)
 
-	if err := a.context.renderer.Init(a.context); err != nil {
+	if err := a.context.initRenderer(); err != nil {
 		return err
 	}
 
-	fmt.Println("OpenGL version", a.context.renderer.Version())
+	fmt.Println("renderer", a.context.rendererName, a.context.renderer.Version())
 
-	if a.vSync {
-		glfw.SwapInterval(1)
-	} else {
-		glfw.SwapInterval(0)
-	}
+	a.applyVSync()
 
 	fps := 0
 	fpsDisplayLastTime := a.currentTime
@@ -247,39 +348,54 @@ func (a *App) Run() error {
 	a.currentTime = glfw.GetTime()
 
 	// initialize input
-	a.context.input.curMouseX, a.context.input.curMouseY = a.window.GetCursorPos()
+	a.context.input.InjectMouseMove(a.window.GetCursorPos())
+	a.context.input.InjectMouseEnter(a.window.GetAttrib(glfw.Hovered) == glfw.True)
+	windowWidth, windowHeight := a.window.GetSize()
+	a.context.input.InjectWindowSize(float64(windowWidth), float64(windowHeight))
+	for joy := glfw.Joystick1; joy <= glfw.JoystickLast; joy++ {
+		if joy.Present() {
+			a.connectJoystick(joy)
+		}
+	}
+	a.pollJoysticks()
 	a.context.input.frameStart(a.currentTime)
 	a.context.input.frameEnd()
 
+	if a.replayInput != "" {
+		if err := a.context.StartReplayFile(a.replayInput); err != nil {
+			return err
+		}
+		defer a.context.StopReplay()
+	}
+	if a.recordInput != "" {
+		if err := a.context.StartRecordingFile(a.recordInput); err != nil {
+			return err
+		}
+		defer a.context.StopRecording()
+	}
+	// frames still in flight need the GL context
+	defer a.context.StopFrameCapture()
+
 	for !a.window.ShouldClose() {
 		a.lastTime = a.currentTime
 		a.currentTime = glfw.GetTime()
 
-		currentScene := a.context.currentScene
-		if currentScene != a.lastScene {
-			result, err := a.context.asyncLoadScene(currentScene)
-			if err != nil {
-				return err
-			}
-			err = <-result
-			if err != nil {
+		if err := a.context.beginFrame(); err != nil {
+			return err
+		}
+		// nothing to draw into while minimized
+		if scrWidth, scrHeight := a.context.ScreenSize(); scrWidth > 0 && scrHeight > 0 {
+			if err := a.context.renderer.Render(a.context.currentScene); err != nil {
 				return err
 			}
-			a.executeBehaviors(true)
-		}
-		a.lastScene = currentScene
-
-		currentScene.updateTransforms()
-		err := a.context.renderer.Render(currentScene)
-		if err != nil {
-			return err
+			a.context.captureFrame()
 		}
 		a.window.SwapBuffers()
 		glfw.PollEvents()
-		a.context.input.curMouseX, a.context.input.curMouseY = a.window.GetCursorPos()
-		a.context.input.frameStart(a.currentTime)
-		a.executeBehaviors(false)
-		a.context.input.frameEnd()
+		a.pollJoysticks()
+		if err := a.context.endFrame(a.currentTime, a.currentTime-a.lastTime); err != nil {
+			return err
+		}
 
 		switch a.context.input.cursorMode {
 		case CURSOR_MODE_NORMAL:
@@ -309,33 +425,123 @@ func (a *App) Stop() {
 	a.window.SetShouldClose(true)
 }
 
-func (a *App) executeBehaviors(runStart bool) {
-	for _, obj := range a.context.currentScene.objects {
-		if obj.enabled && obj.behavior != nil {
-			bctx := BehaviorContext{Context: a.context, Object: obj, Time: a.currentTime, DeltaTime: a.currentTime - a.lastTime}
-			if runStart {
-				obj.behavior.Start(&bctx)
-			} else {
-				obj.behavior.Update(&bctx)
-			}
-		}
-	}
-}
-
 func (a *App) keyCallBack(w *glfw.Window, key glfw.Key, scancode int, action glfw.Action, mods glfw.ModifierKey) {
 	if action == glfw.Press {
-		a.context.input.curKeyState[keyMap[int(key)]] = KEY_STATE_DOWN
+		a.context.input.InjectKey(keyMap[int(key)], true)
 	}
 	if action == glfw.Release {
-		a.context.input.curKeyState[keyMap[int(key)]] = KEY_STATE_UP
+		a.context.input.InjectKey(keyMap[int(key)], false)
 	}
 }
 
 func (a *App) mouseCallBack(w *glfw.Window, key glfw.MouseButton, action glfw.Action, mods glfw.ModifierKey) {
 	if action == glfw.Press {
-		a.context.input.curKeyState[keyMap[int(key)]] = KEY_STATE_DOWN
+		a.context.input.InjectButton(keyMap[int(key)], true)
 	}
 	if action == glfw.Release {
-		a.context.input.curKeyState[keyMap[int(key)]] = KEY_STATE_UP
+		a.context.input.InjectButton(keyMap[int(key)], false)
+	}
+}
+
+func (a *App) cursorPosCallBack(w *glfw.Window, xpos float64, ypos float64) {
+	a.context.input.InjectMouseMove(xpos, ypos)
+}
+
+func (a *App) scrollCallBack(w *glfw.Window, xoff float64, yoff float64) {
+	a.context.input.InjectScroll(xoff, yoff)
+}
+
+func (a *App) charCallBack(w *glfw.Window, char rune) {
+	a.context.input.InjectText(string(char))
+}
+
+func (a *App) cursorEnterCallBack(w *glfw.Window, entered bool) {
+	a.context.input.InjectMouseEnter(entered)
+}
+
+func (a *App) sizeCallBack(w *glfw.Window, width int, height int) {
+	a.context.input.InjectWindowSize(float64(width), float64(height))
+}
+
+func (a *App) framebufferSizeCallBack(w *glfw.Window, width int, height int) {
+	a.context.SetScreenSize(width, height)
+}
+
+// polledJoystick is the state of a joystick last forwarded to the input.
+type polledJoystick struct {
+	gamepad bool
+	axes    []float32
+	buttons []glfw.Action
+}
+
+func joystickDevice(joy glfw.Joystick) int {
+	return int(joy-glfw.Joystick1) + 1
+}
+
+func (a *App) joystickCallBack(joy glfw.Joystick, event glfw.PeripheralEvent) {
+	switch event {
+	case glfw.Connected:
+		a.connectJoystick(joy)
+	case glfw.Disconnected:
+		delete(a.joysticks, joy)
+		a.context.input.InjectJoystickDisconnect(joystickDevice(joy))
+	}
+}
+
+func (a *App) connectJoystick(joy glfw.Joystick) {
+	gamepad := joy.IsGamepad()
+	name := joy.GetName()
+	if gamepad {
+		name = joy.GetGamepadName()
+	}
+	a.joysticks[joy] = &polledJoystick{gamepad: gamepad}
+	a.context.input.InjectJoystickConnect(joystickDevice(joy), name, gamepad)
+}
+
+// pollJoysticks forwards the axes and buttons that changed since the last
+// poll, as GLFW has no callbacks for them, device by device in increasing
+// order so that recordings do not depend on map order.
+func (a *App) pollJoysticks() {
+	joys := make([]glfw.Joystick, 0, len(a.joysticks))
+	for joy := range a.joysticks {
+		joys = append(joys, joy)
+	}
+	sort.Slice(joys, func(i, j int) bool { return joys[i] < joys[j] })
+	for _, joy := range joys {
+		polled := a.joysticks[joy]
+		var axes []float32
+		var buttons []glfw.Action
+		if polled.gamepad {
+			state := joy.GetGamepadState()
+			if state == nil {
+				continue
+			}
+			axes = append(axes, state.Axes[:]...)
+			// triggers rest at -1 in GLFW
+			axes[glfw.AxisLeftTrigger-glfw.AxisLeftX] = (axes[glfw.AxisLeftTrigger-glfw.AxisLeftX] + 1) / 2
+			axes[glfw.AxisRightTrigger-glfw.AxisLeftX] = (axes[glfw.AxisRightTrigger-glfw.AxisLeftX] + 1) / 2
+			buttons = append(buttons, state.Buttons[:]...)
+		} else {
+			axes = joy.GetAxes()
+			buttons = joy.GetButtons()
+		}
+
+		device := joystickDevice(joy)
+		for i, value := range axes {
+			if i >= len(polled.axes) || polled.axes[i] != value {
+				a.context.input.InjectJoystickAxis(device, AXIS_FROM_LEFT_X+i, float64(value))
+			}
+		}
+		for i, action := range buttons {
+			last := glfw.Release
+			if i < len(polled.buttons) {
+				last = polled.buttons[i]
+			}
+			if action != last {
+				a.context.input.InjectJoystickButton(device, i, action == glfw.Press)
+			}
+		}
+		polled.axes = append(polled.axes[:0], axes...)
+		polled.buttons = append(polled.buttons[:0], buttons...)
 	}
 }
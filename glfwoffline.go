@@ -0,0 +1,103 @@
+package wengine
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"io"
+)
+
+// OfflineConfig makes an App render frames offscreen at a fixed frame delta,
+// ignoring the wall clock, instead of running in a window. Behaviors run
+// as usual, and a recorded input can be replayed with Config.ReplayInput:
+// its events are applied in the frames they were recorded in, which still
+// advance by FrameDelta rather than by the recorded deltas.
+type OfflineConfig struct {
+	// Width and Height are the resolution of the frames, the size of the
+	// Config if unset.
+	Width, Height int
+	// FrameDelta is the time in seconds every frame advances by, 1/60 if
+	// unset.
+	FrameDelta float64
+	// Frames is the number of frames to render, until App.Stop is called if
+	// unset.
+	Frames int
+
+	// Dir receives the frames as frame-000000.png, frame-000001.png and so
+	// on. Nothing is written if empty.
+	Dir string
+	// Output, if set, receives the frames as raw 8-bit RGBA pixels, top row
+	// first.
+	Output io.Writer
+}
+
+// runOffline is Run for an offline App. The context version hints are
+// expected to be set.
+func (a *App) runOffline() error {
+	offline := a.offline
+	width, height := offline.Width, offline.Height
+	if width <= 0 || height <= 0 {
+		width, height = a.width, a.height
+	}
+	if width <= 0 || height <= 0 {
+		return errors.New("invalid offline resolution")
+	}
+	frameDelta := offline.FrameDelta
+	if frameDelta <= 0 {
+		frameDelta = 1.0 / 60
+	}
+
+	// the window only provides the GL context, the screen is offscreen
+	glfw.WindowHint(glfw.Visible, glfw.False)
+	window, err := glfw.CreateWindow(width, height, a.title, nil, nil)
+	if err != nil {
+		return errors.New(fmt.Sprint("unable to init window:", err))
+	}
+	a.window = window
+	a.window.MakeContextCurrent()
+
+	a.context.SetScreenSize(width, height)
+	if err := a.context.initRenderer(); err != nil {
+		return err
+	}
+	if renderer, ok := a.context.renderer.(OffscreenRenderer); ok {
+		if err := renderer.SetOffscreen(true); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("renderer", a.context.rendererName, a.context.renderer.Version())
+
+	a.context.input.InjectWindowSize(float64(width), float64(height))
+
+	if a.replayInput != "" {
+		if err := a.context.StartReplayFile(a.replayInput); err != nil {
+			return err
+		}
+		// frames advance by frameDelta, only the events are replayed
+		a.context.player.eventsOnly = true
+		defer a.context.StopReplay()
+	}
+	if a.recordInput != "" {
+		if err := a.context.StartRecordingFile(a.recordInput); err != nil {
+			return err
+		}
+		defer a.context.StopRecording()
+	}
+
+	if offline.Dir != "" || offline.Output != nil {
+		if err := a.context.startFrameSequence(offline.Dir, offline.Output); err != nil {
+			return err
+		}
+	}
+	for frame := 0; offline.Frames <= 0 || frame < offline.Frames; frame++ {
+		if a.window.ShouldClose() {
+			break
+		}
+		if err := a.context.Step(frameDelta); err != nil {
+			a.context.StopFrameCapture()
+			return err
+		}
+	}
+	return a.context.StopFrameCapture()
+}
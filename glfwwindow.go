@@ -0,0 +1,210 @@
+package wengine
+
+import (
+	"errors"
+	"github.com/go-gl/glfw/v3.3/glfw"
+	"image"
+)
+
+type VideoMode struct {
+	Width, Height                int
+	RedBits, GreenBits, BlueBits int
+	RefreshRate                  int
+}
+
+type MonitorInfo struct {
+	Name string
+	// position on the virtual desktop
+	X, Y int
+	// physical size in millimetres
+	PhysicalWidth, PhysicalHeight int
+	CurrentMode                   VideoMode
+	Primary                       bool
+}
+
+func videoMode(mode *glfw.VidMode) VideoMode {
+	return VideoMode{
+		Width:       mode.Width,
+		Height:      mode.Height,
+		RedBits:     mode.RedBits,
+		GreenBits:   mode.GreenBits,
+		BlueBits:    mode.BlueBits,
+		RefreshRate: mode.RefreshRate,
+	}
+}
+
+// Monitors lists the connected monitors. It returns nil unless the app is
+// running, and like every window method of App, it must be called from the
+// goroutine running the app, such as from a behavior.
+func (a *App) Monitors() []MonitorInfo {
+	if a.window == nil {
+		return nil
+	}
+	primary := glfw.GetPrimaryMonitor()
+	monitors := []MonitorInfo{}
+	for _, monitor := range glfw.GetMonitors() {
+		info := MonitorInfo{Name: monitor.GetName(), Primary: monitor == primary}
+		info.X, info.Y = monitor.GetPos()
+		info.PhysicalWidth, info.PhysicalHeight = monitor.GetPhysicalSize()
+		if mode := monitor.GetVideoMode(); mode != nil {
+			info.CurrentMode = videoMode(mode)
+		}
+		monitors = append(monitors, info)
+	}
+	return monitors
+}
+
+// VideoModes lists the video modes of a monitor, by its index in Monitors.
+func (a *App) VideoModes(monitor int) []VideoMode {
+	monitors := a.glfwMonitors()
+	if monitor < 0 || monitor >= len(monitors) {
+		return nil
+	}
+	modes := []VideoMode{}
+	for _, mode := range monitors[monitor].GetVideoModes() {
+		modes = append(modes, videoMode(mode))
+	}
+	return modes
+}
+
+func (a *App) glfwMonitors() []*glfw.Monitor {
+	if a.window == nil {
+		return nil
+	}
+	return glfw.GetMonitors()
+}
+
+// glfwMonitor is the monitor to go full screen on.
+func (a *App) glfwMonitor() *glfw.Monitor {
+	monitors := glfw.GetMonitors()
+	if a.monitor >= 0 && a.monitor < len(monitors) {
+		return monitors[a.monitor]
+	}
+	return glfw.GetPrimaryMonitor()
+}
+
+func (a *App) WindowMode() int {
+	return a.winMode
+}
+
+// SetWindowMode switches between windowed, exclusive and borderless full
+// screen. Exclusive full screen uses the size of the last SetVideoMode, or
+// of the config.
+func (a *App) SetWindowMode(mode int) error {
+	switch mode {
+	case WINDOW_MODE_WINDOWED, WINDOW_MODE_FULL_SCREEN, WINDOW_MODE_BORDERLESS:
+	default:
+		return errors.New("invalid window mode")
+	}
+	if a.window == nil {
+		a.winMode = mode
+		return nil
+	}
+	if a.winMode == WINDOW_MODE_WINDOWED && mode != WINDOW_MODE_WINDOWED {
+		a.windowedX, a.windowedY = a.window.GetPos()
+		a.windowedWidth, a.windowedHeight = a.window.GetSize()
+		a.windowedPlaced = true
+	}
+	a.winMode = mode
+	a.applyWindowMode()
+	return nil
+}
+
+// ToggleFullScreen switches between windowed and the last full screen mode,
+// borderless by default.
+func (a *App) ToggleFullScreen() error {
+	if a.winMode != WINDOW_MODE_WINDOWED {
+		return a.SetWindowMode(WINDOW_MODE_WINDOWED)
+	}
+	if a.lastFullScreenMode == WINDOW_MODE_WINDOWED {
+		return a.SetWindowMode(WINDOW_MODE_BORDERLESS)
+	}
+	return a.SetWindowMode(a.lastFullScreenMode)
+}
+
+// SetMonitor picks the monitor, by its index in Monitors, to go full screen
+// on. A full screen window moves there at once.
+func (a *App) SetMonitor(monitor int) error {
+	if a.window != nil && (monitor < 0 || monitor >= len(glfw.GetMonitors())) {
+		return errors.New("no such monitor")
+	}
+	a.monitor = monitor
+	if a.window != nil && a.winMode != WINDOW_MODE_WINDOWED {
+		a.applyWindowMode()
+	}
+	return nil
+}
+
+// SetVideoMode sets the resolution and refresh rate of exclusive full
+// screen, applied at once if the window is in that mode. The bit depths of
+// mode are ignored.
+func (a *App) SetVideoMode(mode VideoMode) {
+	a.width, a.height = mode.Width, mode.Height
+	a.refreshRate = mode.RefreshRate
+	if a.window != nil && a.winMode == WINDOW_MODE_FULL_SCREEN {
+		a.applyWindowMode()
+	}
+}
+
+func (a *App) applyWindowMode() {
+	switch a.winMode {
+	case WINDOW_MODE_WINDOWED:
+		if !a.windowedPlaced {
+			// started full screen: center the window on the monitor
+			x, y, width, height := a.glfwMonitor().GetWorkarea()
+			a.windowedX = x + (width-a.windowedWidth)/2
+			a.windowedY = y + (height-a.windowedHeight)/2
+			a.windowedPlaced = true
+		}
+		a.window.SetMonitor(nil, a.windowedX, a.windowedY, a.windowedWidth, a.windowedHeight, 0)
+	case WINDOW_MODE_FULL_SCREEN:
+		refreshRate := a.refreshRate
+		if refreshRate == 0 {
+			refreshRate = glfw.DontCare
+		}
+		a.window.SetMonitor(a.glfwMonitor(), 0, 0, a.width, a.height, refreshRate)
+		a.lastFullScreenMode = a.winMode
+	case WINDOW_MODE_BORDERLESS:
+		monitor := a.glfwMonitor()
+		mode := monitor.GetVideoMode()
+		a.window.SetMonitor(monitor, 0, 0, mode.Width, mode.Height, mode.RefreshRate)
+		a.lastFullScreenMode = a.winMode
+	}
+}
+
+func (a *App) SetTitle(title string) {
+	a.title = title
+	if a.window != nil {
+		a.window.SetTitle(title)
+	}
+}
+
+// SetIcon sets the window icon from candidate images of different sizes,
+// the closest to the size the system wants being picked. No image restores
+// the default icon.
+func (a *App) SetIcon(images ...image.Image) {
+	a.icon = images
+	if a.window != nil {
+		a.window.SetIcon(images)
+	}
+}
+
+// SetVSync turns vertical sync on or off.
+func (a *App) SetVSync(vSync bool) {
+	a.vSync = vSync
+	if a.window != nil {
+		a.applyVSync()
+	}
+}
+
+func (a *App) VSync() bool {
+	return a.vSync
+}
+
+func (a *App) applyVSync() {
+	if a.vSync {
+		glfw.SwapInterval(1)
+	} else {
+		glfw.SwapInterval(0)
+	}
+}
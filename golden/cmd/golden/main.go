@@ -0,0 +1,40 @@
+// Command golden renders the canned scenes of package golden and compares
+// them against the reference images, as go test ./golden does, with
+// adjustable tolerances.
+//
+//	go run ./golden/cmd/golden -dir golden/testdata -renderer opengl
+//
+// The references of each renderer are looked up in a directory of its name
+// under -dir, except the software renderer's which are in -dir itself. Run
+// it with -update after an intended rendering change to replace the
+// references, and look at the *.diff.png files left in the directory when a
+// scene fails.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/wxdao/wengine/golden"
+	_ "github.com/wxdao/wengine/opengl"
+	_ "github.com/wxdao/wengine/software"
+	"os"
+)
+
+var dir = flag.String("dir", "golden/testdata", "reference image root `directory`")
+var rendererName = flag.String("renderer", "software", "`name` of the renderer to check")
+var update = flag.Bool("update", false, "replace the reference images")
+var threshold = flag.Float64("threshold", golden.DefaultTolerance.Threshold, "perceptual difference of a pixel counted as equal, 0 to 1")
+var maxDiff = flag.Float64("maxdiff", golden.DefaultTolerance.MaxDiffPixels, "fraction of pixels allowed to differ")
+
+func main() {
+	flag.Parse()
+	tolerance := golden.Tolerance{Threshold: *threshold, MaxDiffPixels: *maxDiff}
+	errs := golden.Run(golden.Scenes, *rendererName, golden.ReferenceDir(*dir, *rendererName), tolerance, *update)
+	for _, err := range errs {
+		fmt.Fprintln(os.Stderr, "FAIL", err)
+	}
+	if len(errs) > 0 {
+		os.Exit(1)
+	}
+	fmt.Println("ok", len(golden.Scenes), "scenes")
+}
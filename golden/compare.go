@@ -0,0 +1,108 @@
+package golden
+
+import (
+	"image"
+	"image/color"
+)
+
+// Tolerance controls how different two images may be before a comparison
+// fails.
+type Tolerance struct {
+	// Threshold is the largest perceptual difference of a pixel, from 0 to 1,
+	// that still counts as equal.
+	Threshold float64
+	// MaxDiffPixels is the fraction of pixels, from 0 to 1, allowed to differ.
+	MaxDiffPixels float64
+}
+
+// DefaultTolerance absorbs rounding differences between platforms while
+// still catching a missing light or texture.
+var DefaultTolerance = Tolerance{Threshold: 0.02, MaxDiffPixels: 0.001}
+
+// Result is the outcome of comparing an image against its reference.
+type Result struct {
+	// DiffPixels is the number of pixels that differ beyond the threshold.
+	DiffPixels int
+	// MaxDelta is the largest perceptual difference found, from 0 to 1.
+	MaxDelta float64
+	// Diff marks differing pixels in red over a faded copy of the reference.
+	Diff *image.RGBA
+	// Passed is false when the sizes differ or too many pixels differ.
+	Passed bool
+}
+
+// maxYIQDelta is the squared distance between black and white in the
+// weighted YIQ space used by yiqDelta.
+const maxYIQDelta = 35215
+
+// Compare compares got against want pixel by pixel in YIQ color space, which
+// weights differences closer to how they are perceived than plain RGB does.
+func Compare(got, want image.Image, tolerance Tolerance) Result {
+	bounds := want.Bounds()
+	result := Result{Diff: image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))}
+	if got.Bounds().Size() != bounds.Size() {
+		result.DiffPixels = bounds.Dx() * bounds.Dy()
+		result.MaxDelta = 1
+		return result
+	}
+
+	offset := got.Bounds().Min.Sub(bounds.Min)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			wantColor := color.RGBAModel.Convert(want.At(x, y)).(color.RGBA)
+			gotColor := color.RGBAModel.Convert(got.At(x+offset.X, y+offset.Y)).(color.RGBA)
+			delta := yiqDelta(gotColor, wantColor) / maxYIQDelta
+			if delta > result.MaxDelta {
+				result.MaxDelta = delta
+			}
+
+			var mark color.RGBA
+			if delta > tolerance.Threshold {
+				result.DiffPixels++
+				mark = color.RGBA{255, 0, 0, 255}
+			} else {
+				gray := uint8(255 - (255-luma(wantColor))/4)
+				mark = color.RGBA{gray, gray, gray, 255}
+			}
+			result.Diff.SetRGBA(x-bounds.Min.X, y-bounds.Min.Y, mark)
+		}
+	}
+	result.Passed = float64(result.DiffPixels) <= tolerance.MaxDiffPixels*float64(bounds.Dx()*bounds.Dy())
+	return result
+}
+
+func yiqDelta(a, b color.RGBA) float64 {
+	ar, ag, ab := blend(a)
+	br, bg, bb := blend(b)
+	dy := yiqY(ar, ag, ab) - yiqY(br, bg, bb)
+	di := yiqI(ar, ag, ab) - yiqI(br, bg, bb)
+	dq := yiqQ(ar, ag, ab) - yiqQ(br, bg, bb)
+	return 0.5053*dy*dy + 0.299*di*di + 0.1957*dq*dq
+}
+
+// blend composites a premultiplied color over white.
+func blend(c color.RGBA) (r, g, b float64) {
+	white := float64(255 - c.A)
+	return float64(c.R) + white, float64(c.G) + white, float64(c.B) + white
+}
+
+func yiqY(r, g, b float64) float64 {
+	return r*0.29889531 + g*0.58662247 + b*0.11448223
+}
+
+func yiqI(r, g, b float64) float64 {
+	return r*0.59597799 - g*0.27417610 - b*0.32180189
+}
+
+func yiqQ(r, g, b float64) float64 {
+	return r*0.21147017 - g*0.52261711 + b*0.31114694
+}
+
+func luma(c color.RGBA) uint8 {
+	r, g, b := blend(c)
+	y := yiqY(r, g, b)
+	if y > 255 {
+		y = 255
+	}
+	return uint8(y)
+}
@@ -0,0 +1,79 @@
+package golden
+
+/*
+#cgo pkg-config: egl
+#include <EGL/egl.h>
+#include <EGL/eglext.h>
+
+// makeGLContext makes a surfaceless OpenGL 3.2 core context current on the
+// calling thread. It returns 0 or the step that failed.
+static int makeGLContext(EGLDisplay *display, EGLContext *context) {
+	PFNEGLGETPLATFORMDISPLAYEXTPROC getPlatformDisplay = (PFNEGLGETPLATFORMDISPLAYEXTPROC)eglGetProcAddress("eglGetPlatformDisplayEXT");
+	if (getPlatformDisplay == NULL) {
+		return 1;
+	}
+	*display = getPlatformDisplay(EGL_PLATFORM_SURFACELESS_MESA, EGL_DEFAULT_DISPLAY, NULL);
+	if (*display == EGL_NO_DISPLAY) {
+		return 2;
+	}
+	if (!eglInitialize(*display, NULL, NULL)) {
+		return 3;
+	}
+	if (!eglBindAPI(EGL_OPENGL_API)) {
+		eglTerminate(*display);
+		return 4;
+	}
+	EGLint attributes[] = {
+		EGL_CONTEXT_MAJOR_VERSION, 3,
+		EGL_CONTEXT_MINOR_VERSION, 2,
+		EGL_CONTEXT_OPENGL_PROFILE_MASK, EGL_CONTEXT_OPENGL_CORE_PROFILE_BIT,
+		EGL_NONE,
+	};
+	*context = eglCreateContext(*display, EGL_NO_CONFIG_KHR, EGL_NO_CONTEXT, attributes);
+	if (*context == EGL_NO_CONTEXT) {
+		eglTerminate(*display);
+		return 5;
+	}
+	if (!eglMakeCurrent(*display, EGL_NO_SURFACE, EGL_NO_SURFACE, *context)) {
+		eglDestroyContext(*display, *context);
+		eglTerminate(*display);
+		return 6;
+	}
+	return 0;
+}
+
+static void releaseGLContext(EGLDisplay display, EGLContext context) {
+	eglMakeCurrent(display, EGL_NO_SURFACE, EGL_NO_SURFACE, EGL_NO_CONTEXT);
+	eglDestroyContext(display, context);
+	eglTerminate(display);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+var glContextSteps = []string{"", "no surfaceless platform", "no display", "unable to init EGL", "no OpenGL API", "unable to create context", "unable to make context current"}
+
+// newGLContext makes a GL context without a window current on the calling
+// goroutine, which stays on its thread until release is called. Mesa is told
+// to use llvmpipe, so that references do not depend on the GPU.
+func newGLContext() (release func(), err error) {
+	if _, set := os.LookupEnv("LIBGL_ALWAYS_SOFTWARE"); !set {
+		os.Setenv("LIBGL_ALWAYS_SOFTWARE", "1")
+	}
+	runtime.LockOSThread()
+	var display C.EGLDisplay
+	var context C.EGLContext
+	if step := C.makeGLContext(&display, &context); step != 0 {
+		runtime.UnlockOSThread()
+		return nil, fmt.Errorf("%w: %s", ErrNoGLContext, glContextSteps[step])
+	}
+	return func() {
+		C.releaseGLContext(display, context)
+		runtime.UnlockOSThread()
+	}, nil
+}
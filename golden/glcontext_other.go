@@ -0,0 +1,8 @@
+//go:build !linux
+
+package golden
+
+// newGLContext is only implemented on Linux, with Mesa.
+func newGLContext() (release func(), err error) {
+	return nil, ErrNoGLContext
+}
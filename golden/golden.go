@@ -0,0 +1,161 @@
+// Package golden renders canned scenes offscreen and compares them against
+// reference images, to catch rendering regressions without looking at a
+// window. TestGolden checks them with go test, go test -update replaces the
+// references.
+//
+// Every renderer has its own references, as the software renderer implements
+// the lighting of the opengl renderer but neither its shadows nor its
+// deferred passes. The opengl renderer runs headless on a surfaceless Mesa
+// context with llvmpipe; where none can be created, as outside Linux, Render
+// returns ErrNoGLContext and its tests are skipped.
+package golden
+
+import (
+	"errors"
+	"fmt"
+	"github.com/wxdao/wengine"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+)
+
+// ErrNoGLContext is returned by Render for the opengl renderer when no GL
+// context can be created.
+var ErrNoGLContext = errors.New("no GL context")
+
+// Scene is a canned scene to render.
+type Scene struct {
+	Name          string
+	Width, Height int
+	// Frames is the number of frames stepped before the image is taken, 1 if
+	// unset.
+	Frames int
+	// Setup registers the assets and the scene of the context and applies
+	// the scene.
+	Setup func(ctx *wengine.Context) error
+}
+
+// Render renders the scene with the named renderer, which must be registered
+// and implement wengine.CapturingRenderer. Renderers implementing
+// wengine.OffscreenRenderer are made to draw offscreen.
+func Render(scene Scene, rendererName string) (*image.RGBA, error) {
+	if rendererName == "opengl" {
+		release, err := newGLContext()
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+	}
+
+	ctx := wengine.NewContext(rendererName)
+	if ctx.RendererName() != rendererName {
+		return nil, errors.New("no such renderer: " + rendererName)
+	}
+	renderer, ok := ctx.Renderer().(wengine.CapturingRenderer)
+	if !ok {
+		return nil, errors.New("renderer cannot capture: " + rendererName)
+	}
+	ctx.SetScreenSize(scene.Width, scene.Height)
+	if err := ctx.InitRenderer(); err != nil {
+		return nil, err
+	}
+	if offscreen, ok := ctx.Renderer().(wengine.OffscreenRenderer); ok {
+		if err := offscreen.SetOffscreen(true); err != nil {
+			return nil, err
+		}
+	}
+	if err := scene.Setup(ctx); err != nil {
+		return nil, err
+	}
+
+	frames := scene.Frames
+	if frames <= 0 {
+		frames = 1
+	}
+	for i := 0; i < frames; i++ {
+		if err := ctx.Step(1.0 / 60); err != nil {
+			return nil, err
+		}
+	}
+	return renderer.Capture(nil)
+}
+
+// ReferenceDir is the directory under root holding the references of the
+// named renderer: root itself for the software renderer, a subdirectory of
+// the renderer's name for the others.
+func ReferenceDir(root string, rendererName string) string {
+	if rendererName == "software" {
+		return root
+	}
+	return filepath.Join(root, rendererName)
+}
+
+// Check compares img against dir/name.png. On failure, the image is written
+// to dir/name.actual.png and the diff to dir/name.diff.png. With update set,
+// img replaces the reference instead.
+func Check(dir string, name string, img image.Image, tolerance Tolerance, update bool) (Result, error) {
+	referencePath := filepath.Join(dir, name+".png")
+	if update {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return Result{}, err
+		}
+		return Result{Passed: true}, writePNG(referencePath, img)
+	}
+
+	reference, err := readPNG(referencePath)
+	if err != nil {
+		return Result{}, err
+	}
+	result := Compare(img, reference, tolerance)
+	if result.Passed {
+		os.Remove(filepath.Join(dir, name+".actual.png"))
+		os.Remove(filepath.Join(dir, name+".diff.png"))
+		return result, nil
+	}
+	if err := writePNG(filepath.Join(dir, name+".actual.png"), img); err != nil {
+		return result, err
+	}
+	if err := writePNG(filepath.Join(dir, name+".diff.png"), result.Diff); err != nil {
+		return result, err
+	}
+	return result, fmt.Errorf("%s: %d pixels differ, max delta %.4f", name, result.DiffPixels, result.MaxDelta)
+}
+
+// Run renders every scene and checks it against the references in dir.
+// Failures do not stop the run; their errors are returned together.
+func Run(scenes []Scene, rendererName string, dir string, tolerance Tolerance, update bool) []error {
+	errs := []error{}
+	for _, scene := range scenes {
+		img, err := Render(scene, rendererName)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", scene.Name, err))
+			continue
+		}
+		if _, err := Check(dir, scene.Name, img, tolerance, update); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func readPNG(path string) (image.Image, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return png.Decode(file)
+}
+
+func writePNG(path string, img image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, img); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,85 @@
+package golden
+
+import (
+	"errors"
+	"flag"
+	_ "github.com/wxdao/wengine/opengl"
+	_ "github.com/wxdao/wengine/software"
+	"image"
+	"image/color"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "replace the reference images in testdata")
+
+func TestGolden(t *testing.T) {
+	checkScenes(t, "software")
+}
+
+// TestGoldenOpenGL needs Mesa with EGL, and is skipped without it.
+func TestGoldenOpenGL(t *testing.T) {
+	checkScenes(t, "opengl")
+}
+
+func checkScenes(t *testing.T, rendererName string) {
+	for _, scene := range Scenes {
+		t.Run(scene.Name, func(t *testing.T) {
+			img, err := Render(scene, rendererName)
+			if errors.Is(err, ErrNoGLContext) {
+				t.Skip(err)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Check(ReferenceDir("testdata", rendererName), scene.Name, img, DefaultTolerance, *update); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func uniformImage(width, height int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
+
+func TestCompare(t *testing.T) {
+	gray := color.RGBA{128, 128, 128, 255}
+	tests := []struct {
+		name       string
+		got        *image.RGBA
+		change     func(img *image.RGBA)
+		wantPassed bool
+		wantDiff   int
+	}{
+		{"identical", uniformImage(100, 100, gray), nil, true, 0},
+		{"rounding", uniformImage(100, 100, gray), func(img *image.RGBA) {
+			for i := 0; i < len(img.Pix); i += 4 {
+				img.Pix[i]++
+			}
+		}, true, 0},
+		{"one pixel off", uniformImage(100, 100, gray), func(img *image.RGBA) {
+			img.SetRGBA(10, 10, color.RGBA{255, 255, 255, 255})
+		}, true, 1},
+		{"many pixels off", uniformImage(100, 100, gray), func(img *image.RGBA) {
+			for x := 0; x < 100; x++ {
+				img.SetRGBA(x, 10, color.RGBA{0, 0, 0, 255})
+			}
+		}, false, 100},
+		{"size differs", uniformImage(100, 50, gray), nil, false, 100 * 100},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.change != nil {
+				test.change(test.got)
+			}
+			result := Compare(test.got, uniformImage(100, 100, gray), DefaultTolerance)
+			if result.Passed != test.wantPassed || result.DiffPixels != test.wantDiff {
+				t.Errorf("passed %v with %d pixels differing, want %v with %d", result.Passed, result.DiffPixels, test.wantPassed, test.wantDiff)
+			}
+		})
+	}
+}
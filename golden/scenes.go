@@ -0,0 +1,294 @@
+package golden
+
+import (
+	"fmt"
+	"github.com/go-gl/mathgl/mgl32"
+	"github.com/wxdao/wengine"
+	"image"
+	"image/color"
+)
+
+// Scenes are the canned scenes checked by the golden runner. They cover each
+// light source, soft and hard shadows, several lights adding up, textures,
+// several cameras sharing the screen, a camera drawing into a texture
+// sampled by a material, and lights too bright for the screen brought back
+// by tone mapping and exposure.
+var Scenes = []Scene{
+	{Name: "directional", Width: 160, Height: 120, Setup: setupDirectional},
+	{Name: "point", Width: 160, Height: 120, Setup: setupPoint},
+	{Name: "spot", Width: 160, Height: 120, Setup: setupSpot},
+	{Name: "shadows", Width: 160, Height: 120, Setup: setupShadows},
+	{Name: "lights", Width: 160, Height: 120, Setup: setupLights},
+	{Name: "textured", Width: 160, Height: 120, Setup: setupTextured},
+	{Name: "viewports", Width: 160, Height: 120, Setup: setupViewports},
+	{Name: "rendertexture", Width: 160, Height: 120, Setup: setupRenderTexture},
+	{Name: "hdr", Width: 160, Height: 120, Setup: setupHDR},
+	{Name: "autoexposure", Width: 160, Height: 120, Frames: 4, Setup: setupAutoExposure},
+}
+
+// newStage creates a scene with a camera looking at a cube standing on a
+// floor, with no light.
+func newStage(ctx *wengine.Context, cubeMaterial *wengine.MeshMaterialAsset) *wengine.Scene {
+	ctx.RegisterAsset("cubeMesh", wengine.DefaultCubeMeshAsset())
+	ctx.RegisterAsset("floorMesh", wengine.DefaultPlaneMeshAsset())
+	ctx.RegisterAsset("cubeMaterial", cubeMaterial)
+	ctx.RegisterAsset("floorMaterial", &wengine.MeshMaterialAsset{DiffuseColor: mgl32.Vec4{0.7, 0.7, 0.7, 1}})
+
+	scene := wengine.NewScene()
+
+	camera := &wengine.CameraComponent{}
+	camera.ViewportX, camera.ViewportY, camera.ViewportW, camera.ViewportH = 0, 0, 1, 1
+	camera.ClearColor, camera.ClearDepth = true, true
+	camera.Mode = wengine.CAMERA_MODE_PERSPECTIVE
+	camera.FOV = mgl32.DegToRad(60)
+	camera.FarPlane = 100
+	camera.NearPlane = 0.3
+	camera.Ambient = mgl32.Vec3{0.2, 0.2, 0.2}
+	cameraObject := wengine.NewObject()
+	cameraObject.SetPosition(mgl32.Vec3{4, 3, 6})
+	cameraObject.LookAt(mgl32.Vec3{0, -0.5, 0}, mgl32.Vec3{0, 1, 0})
+	cameraObject.AttachComponent(camera)
+	cameraObject.SetEnabled(true)
+	scene.RegisterObject("camera", cameraObject)
+
+	cubeObject := wengine.NewObject()
+	cubeObject.Rotate(mgl32.DegToRad(30), mgl32.Vec3{0, 1, 0})
+	cubeObject.AttachComponent(&wengine.MeshComponent{
+		Mesh:          "cubeMesh",
+		Material:      "cubeMaterial",
+		CastShadow:    true,
+		ReceiveShader: true,
+	})
+	cubeObject.SetEnabled(true)
+	scene.RegisterObject("cube", cubeObject)
+
+	floorObject := wengine.NewObject()
+	floorObject.Translate(mgl32.Vec3{0, -1, 0})
+	floorObject.Scale(mgl32.Vec3{20, 1, 20})
+	floorObject.AttachComponent(&wengine.MeshComponent{
+		Mesh:          "floorMesh",
+		Material:      "floorMaterial",
+		CastShadow:    false,
+		ReceiveShader: true,
+	})
+	floorObject.SetEnabled(true)
+	scene.RegisterObject("floor", floorObject)
+
+	return scene
+}
+
+func addLight(scene *wengine.Scene, name string, light *wengine.LightComponent, position mgl32.Vec3, target mgl32.Vec3) {
+	lightObject := wengine.NewObject()
+	lightObject.SetPosition(position)
+	lightObject.LookAt(target, mgl32.Vec3{0, 0, -1})
+	lightObject.AttachComponent(light)
+	lightObject.SetEnabled(true)
+	scene.RegisterObject(name, lightObject)
+}
+
+func apply(ctx *wengine.Context, scene *wengine.Scene) {
+	ctx.RegisterScene("golden", scene)
+	ctx.ApplyScene("golden")
+}
+
+func setupDirectional(ctx *wengine.Context) error {
+	scene := newStage(ctx, &wengine.MeshMaterialAsset{DiffuseColor: mgl32.Vec4{0.9, 0.4, 0.1, 1}})
+	addLight(scene, "dirLight", &wengine.LightComponent{
+		LightSource: wengine.LIGHT_SOURCE_DIRECTIONAL,
+		ShadowType:  wengine.LIGHT_SHADOW_TYPE_HARD,
+		Diffuse:     mgl32.Vec3{0.8, 0.8, 0.8},
+		Specular:    mgl32.Vec3{0.2, 0.2, 0.2},
+	}, mgl32.Vec3{3, 10, 5}, mgl32.Vec3{})
+	apply(ctx, scene)
+	return nil
+}
+
+func setupPoint(ctx *wengine.Context) error {
+	scene := newStage(ctx, &wengine.MeshMaterialAsset{DiffuseColor: mgl32.Vec4{0.2, 0.6, 0.9, 1}})
+	addLight(scene, "pointLight", &wengine.LightComponent{
+		LightSource: wengine.LIGHT_SOURCE_POINT,
+		ShadowType:  wengine.LIGHT_SHADOW_TYPE_NONE,
+		Range:       10,
+		Diffuse:     mgl32.Vec3{1, 1, 1},
+		Specular:    mgl32.Vec3{0.5, 0.5, 0.5},
+	}, mgl32.Vec3{2, 3, 2}, mgl32.Vec3{})
+	apply(ctx, scene)
+	return nil
+}
+
+func setupSpot(ctx *wengine.Context) error {
+	scene := newStage(ctx, &wengine.MeshMaterialAsset{DiffuseColor: mgl32.Vec4{0.3, 0.8, 0.3, 1}})
+	addLight(scene, "spotLight", &wengine.LightComponent{
+		LightSource: wengine.LIGHT_SOURCE_SPOT,
+		ShadowType:  wengine.LIGHT_SHADOW_TYPE_HARD,
+		Angle:       mgl32.DegToRad(40),
+		Range:       12,
+		Diffuse:     mgl32.Vec3{3, 3, 3},
+		Specular:    mgl32.Vec3{1, 1, 1},
+	}, mgl32.Vec3{0, 6, 0}, mgl32.Vec3{0, -1, 0})
+	apply(ctx, scene)
+	return nil
+}
+
+func setupShadows(ctx *wengine.Context) error {
+	scene := newStage(ctx, &wengine.MeshMaterialAsset{DiffuseColor: mgl32.Vec4{0.8, 0.8, 0.3, 1}})
+	addLight(scene, "pointLight", &wengine.LightComponent{
+		LightSource: wengine.LIGHT_SOURCE_POINT,
+		ShadowType:  wengine.LIGHT_SHADOW_TYPE_SOFT,
+		Range:       12,
+		Diffuse:     mgl32.Vec3{1.5, 1.5, 1.5},
+		Specular:    mgl32.Vec3{0.5, 0.5, 0.5},
+	}, mgl32.Vec3{-1, 3, -1.5}, mgl32.Vec3{})
+	apply(ctx, scene)
+	return nil
+}
+
+func setupLights(ctx *wengine.Context) error {
+	scene := newStage(ctx, &wengine.MeshMaterialAsset{DiffuseColor: mgl32.Vec4{0.9, 0.9, 0.9, 1}})
+	colors := []mgl32.Vec3{{1, 0.2, 0.2}, {0.2, 1, 0.2}, {0.2, 0.2, 1}, {1, 1, 0.2}}
+	positions := []mgl32.Vec3{{2, 0.5, 2}, {-2, 0.5, 2}, {2, 0.5, -2}, {-2, 0.5, -2}}
+	for i, color := range colors {
+		addLight(scene, fmt.Sprint("pointLight", i), &wengine.LightComponent{
+			LightSource: wengine.LIGHT_SOURCE_POINT,
+			ShadowType:  wengine.LIGHT_SHADOW_TYPE_NONE,
+			Range:       6,
+			Diffuse:     color,
+			Specular:    color.Mul(0.3),
+		}, positions[i], mgl32.Vec3{})
+	}
+	apply(ctx, scene)
+	return nil
+}
+
+func setupTextured(ctx *wengine.Context) error {
+	scene := newStage(ctx, &wengine.MeshMaterialAsset{DiffuseImage: checkerImage(64, 8)})
+	addLight(scene, "dirLight", &wengine.LightComponent{
+		LightSource: wengine.LIGHT_SOURCE_DIRECTIONAL,
+		ShadowType:  wengine.LIGHT_SHADOW_TYPE_NONE,
+		Diffuse:     mgl32.Vec3{0.8, 0.8, 0.8},
+		Specular:    mgl32.Vec3{0.2, 0.2, 0.2},
+	}, mgl32.Vec3{3, 10, 5}, mgl32.Vec3{})
+	apply(ctx, scene)
+	return nil
+}
+
+func setupViewports(ctx *wengine.Context) error {
+	if err := setupDirectional(ctx); err != nil {
+		return err
+	}
+	scene := ctx.CurrentScene()
+
+	camera := &wengine.CameraComponent{}
+	camera.ViewportX, camera.ViewportY, camera.ViewportW, camera.ViewportH = 0, 0.6, 0.4, 0.4
+	camera.Depth = -1
+	camera.ClearColor, camera.ClearDepth = true, true
+	camera.Mode = wengine.CAMERA_MODE_ORTHOGRAPHIC
+	camera.Width = 6
+	camera.FarPlane = 100
+	camera.NearPlane = 0.3
+	camera.Ambient = mgl32.Vec3{0.2, 0.2, 0.2}
+	cameraObject := wengine.NewObject()
+	cameraObject.SetPosition(mgl32.Vec3{0, 10, 0})
+	cameraObject.LookAt(mgl32.Vec3{}, mgl32.Vec3{0, 0, -1})
+	cameraObject.AttachComponent(camera)
+	cameraObject.SetEnabled(true)
+	scene.RegisterObject("topCamera", cameraObject)
+	return nil
+}
+
+func setupRenderTexture(ctx *wengine.Context) error {
+	if err := setupDirectional(ctx); err != nil {
+		return err
+	}
+	scene := ctx.CurrentScene()
+	ctx.RegisterAsset("monitor", &wengine.RenderTextureAsset{Width: 64, Height: 48})
+	ctx.RegisterAsset("monitorMaterial", &wengine.MeshMaterialAsset{RenderTexture: "monitor"})
+
+	// the depth alone would render it after the main camera, the monitor
+	// sampling its texture moves it first
+	camera := &wengine.CameraComponent{}
+	camera.ViewportX, camera.ViewportY, camera.ViewportW, camera.ViewportH = 0, 0, 1, 1
+	camera.Depth = -1
+	camera.TargetTexture = "monitor"
+	camera.ClearColor, camera.ClearDepth = true, true
+	camera.Mode = wengine.CAMERA_MODE_ORTHOGRAPHIC
+	camera.Width = 3
+	camera.FarPlane = 100
+	camera.NearPlane = 0.3
+	camera.Ambient = mgl32.Vec3{0.2, 0.2, 0.2}
+	cameraObject := wengine.NewObject()
+	cameraObject.SetPosition(mgl32.Vec3{0, 10, 0})
+	cameraObject.LookAt(mgl32.Vec3{}, mgl32.Vec3{0, 0, -1})
+	cameraObject.AttachComponent(camera)
+	cameraObject.SetEnabled(true)
+	scene.RegisterObject("monitorCamera", cameraObject)
+
+	monitorObject := wengine.NewObject()
+	monitorObject.Translate(mgl32.Vec3{-2.5, -0.9, 1.5})
+	monitorObject.Scale(mgl32.Vec3{1.2, 1, 0.9})
+	monitorObject.AttachComponent(&wengine.MeshComponent{
+		Mesh:          "floorMesh",
+		Material:      "monitorMaterial",
+		CastShadow:    false,
+		ReceiveShader: false,
+	})
+	monitorObject.SetEnabled(true)
+	scene.RegisterObject("monitor", monitorObject)
+	return nil
+}
+
+func mainCamera(scene *wengine.Scene) *wengine.CameraComponent {
+	return scene.Objects()["camera"].ComponentsOfType(wengine.COMPO_CAMERA)[0].(*wengine.CameraComponent)
+}
+
+func setupHDR(ctx *wengine.Context) error {
+	scene := newStage(ctx, &wengine.MeshMaterialAsset{DiffuseColor: mgl32.Vec4{0.9, 0.4, 0.1, 1}})
+	addLight(scene, "pointLight", &wengine.LightComponent{
+		LightSource: wengine.LIGHT_SOURCE_POINT,
+		ShadowType:  wengine.LIGHT_SHADOW_TYPE_NONE,
+		Range:       10,
+		Diffuse:     mgl32.Vec3{8, 8, 8},
+		Specular:    mgl32.Vec3{4, 4, 4},
+	}, mgl32.Vec3{2, 3, 2}, mgl32.Vec3{})
+	camera := mainCamera(scene)
+	camera.ToneMapping = wengine.TONE_MAPPING_ACES
+	camera.Exposure = -1
+	apply(ctx, scene)
+	return nil
+}
+
+func setupAutoExposure(ctx *wengine.Context) error {
+	scene := newStage(ctx, &wengine.MeshMaterialAsset{DiffuseImage: checkerImage(64, 8)})
+	addLight(scene, "dirLight", &wengine.LightComponent{
+		LightSource: wengine.LIGHT_SOURCE_DIRECTIONAL,
+		ShadowType:  wengine.LIGHT_SHADOW_TYPE_NONE,
+		Diffuse:     mgl32.Vec3{40, 40, 40},
+		Specular:    mgl32.Vec3{10, 10, 10},
+	}, mgl32.Vec3{3, 10, 5}, mgl32.Vec3{})
+	camera := mainCamera(scene)
+	camera.Ambient = mgl32.Vec3{10, 10, 10}
+	camera.ToneMapping = wengine.TONE_MAPPING_FILMIC
+	camera.AutoExposure = true
+	apply(ctx, scene)
+	return nil
+}
+
+// checkerImage makes a size x size texture of cells x cells squares, with a
+// red corner at the origin so flipped uvs show up.
+func checkerImage(size, cells int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	cell := size / cells
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			c := color.RGBA{40, 40, 40, 255}
+			if (x/cell+y/cell)%2 == 0 {
+				c = color.RGBA{230, 230, 230, 255}
+			}
+			if x < cell && y >= size-cell {
+				c = color.RGBA{220, 30, 30, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
@@ -10,11 +10,21 @@ const (
 const (
 	AXIS_SOURCE_KEY = iota
 	AXIS_SOURCE_MOUSE
+	AXIS_SOURCE_JOYSTICK
+	AXIS_SOURCE_SCROLL
 )
 
 const (
 	AXIS_FROM_X = iota
 	AXIS_FROM_Y
+
+	// joystick, in the standard gamepad layout
+	AXIS_FROM_LEFT_X
+	AXIS_FROM_LEFT_Y
+	AXIS_FROM_RIGHT_X
+	AXIS_FROM_RIGHT_Y
+	AXIS_FROM_LEFT_TRIGGER
+	AXIS_FROM_RIGHT_TRIGGER
 )
 
 type AxisMeta struct {
@@ -23,9 +33,12 @@ type AxisMeta struct {
 	// key
 	PositiveKey, NegativeKey string
 
-	// mouse & joystick
+	// mouse, scroll & joystick
 	From int
 
+	// joystick: device number from 1, or 0 for any connected device
+	Joystick int
+
 	Gravity, Dead, Sensitivity float64
 	Invert                     bool
 }
@@ -45,6 +58,28 @@ type Input struct {
 	axes      map[string][]*AxisMeta
 	axisValue map[*AxisMeta]float64
 
+	// scroll offset and text typed in the current frame
+	scrollX, scrollY float64
+	text             string
+
+	preMouseInside, curMouseInside bool
+	windowWidth, windowHeight      float64
+
+	joysticks map[int]*joystickState
+
+	actionMaps                     map[string]*ActionMap
+	preActionState, curActionState map[string]bool
+	bindingListener                func(binding Binding) bool
+	// modifierPressedAlone is a modifier pressed while listening, bound
+	// alone if released before another key
+	modifierPressedAlone string
+	// listenedKeys are keys pressed while listening, which trigger no
+	// action until released
+	listenedKeys map[string]bool
+
+	queue       inputQueue
+	frameEvents []InputEvent
+
 	cursorMode int
 
 	currentTime, lastTime float64
@@ -56,10 +91,19 @@ func newInput() *Input {
 		curKeyState: map[string]int{},
 		axes:        map[string][]*AxisMeta{},
 		axisValue:   map[*AxisMeta]float64{},
+		joysticks:   map[int]*joystickState{},
+
+		actionMaps:     map[string]*ActionMap{},
+		preActionState: map[string]bool{},
+		curActionState: map[string]bool{},
+		listenedKeys:   map[string]bool{},
 	}
 }
 
 func (i *Input) frameStart(currentTime float64) {
+	i.applyEvents()
+	i.listenForBinding()
+	i.updateActions()
 	i.currentTime = currentTime
 	if i.lastTime == 0 {
 		i.lastTime = i.currentTime
@@ -105,8 +149,18 @@ func (i *Input) frameStart(currentTime float64) {
 				value = meta.Sensitivity * (i.curMouseY - i.preMouseY)
 
 			}
+		case AXIS_SOURCE_JOYSTICK:
+			value = i.joystickAxisValue(meta)
+		case AXIS_SOURCE_SCROLL:
+			switch meta.From {
+			case AXIS_FROM_X:
+				value = meta.Sensitivity * i.scrollX
+			case AXIS_FROM_Y:
+				value = meta.Sensitivity * i.scrollY
+			}
 		}
-		if math.Abs(value) <= meta.Dead {
+		// joystickAxisValue applies the dead zone itself
+		if meta.Source != AXIS_SOURCE_JOYSTICK && math.Abs(value) <= meta.Dead {
 			value = 0
 		}
 		i.axisValue[meta] = value
@@ -117,10 +171,58 @@ func (i *Input) frameEnd() {
 	for k, v := range i.curKeyState {
 		i.preKeyState[k] = v
 	}
+	for k, v := range i.curActionState {
+		i.preActionState[k] = v
+	}
 	i.preMouseX, i.preMouseY = i.curMouseX, i.curMouseY
+	i.preMouseInside = i.curMouseInside
+	i.scrollX, i.scrollY = 0, 0
+	i.text = ""
+	i.frameEvents = nil
 	i.lastTime = i.currentTime
 }
 
+// TypedText returns the text typed in the current frame.
+func (i *Input) TypedText() string {
+	return i.text
+}
+
+// ScrollDelta returns how far the scroll wheel moved in the current frame.
+func (i *Input) ScrollDelta() (x, y float64) {
+	return i.scrollX, i.scrollY
+}
+
+// MousePosition returns the cursor position in window coordinates, from the
+// top left corner.
+func (i *Input) MousePosition() (x, y float64) {
+	return i.curMouseX, i.curMouseY
+}
+
+// NormalizedMousePosition returns the cursor position from 0 to 1 across
+// the window, from the bottom left corner like camera viewports.
+func (i *Input) NormalizedMousePosition() (x, y float64) {
+	if i.windowWidth <= 0 || i.windowHeight <= 0 {
+		return 0, 0
+	}
+	return i.curMouseX / i.windowWidth, 1 - i.curMouseY/i.windowHeight
+}
+
+// MouseInside reports whether the cursor is over the window.
+func (i *Input) MouseInside() bool {
+	return i.curMouseInside
+}
+
+// MouseEntered reports whether the cursor entered the window in the current
+// frame.
+func (i *Input) MouseEntered() bool {
+	return i.curMouseInside && !i.preMouseInside
+}
+
+// MouseLeft reports whether the cursor left the window in the current frame.
+func (i *Input) MouseLeft() bool {
+	return !i.curMouseInside && i.preMouseInside
+}
+
 func (i *Input) SetCursorMode(mode int) {
 	i.cursorMode = mode
 }
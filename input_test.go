@@ -0,0 +1,234 @@
+package wengine
+
+import (
+	"math"
+	"testing"
+)
+
+// inputFrame is the input injected before one frame and what is expected
+// of it, frames being an eighth of a second apart.
+type inputFrame struct {
+	inject func(input *Input)
+	check  func(t *testing.T, input *Input)
+}
+
+func runInputFrames(t *testing.T, input *Input, frames []inputFrame) {
+	for n, frame := range frames {
+		if frame.inject != nil {
+			frame.inject(input)
+		}
+		input.frameStart(1 + float64(n)/8)
+		if frame.check != nil {
+			frame.check(t, input)
+		}
+		input.frameEnd()
+	}
+}
+
+func TestGetKey(t *testing.T) {
+	// key, down, pressed and released in each frame
+	type keyState struct{ key, down, up bool }
+	tests := []struct {
+		name   string
+		inject []func(input *Input)
+		want   []keyState
+	}{
+		{"press and hold", []func(input *Input){
+			func(input *Input) { input.InjectKey("a", true) }, nil, nil,
+		}, []keyState{{true, true, false}, {true, false, false}, {true, false, false}}},
+		{"press and release", []func(input *Input){
+			func(input *Input) { input.InjectKey("a", true) },
+			func(input *Input) { input.InjectKey("a", false) },
+			nil,
+		}, []keyState{{true, true, false}, {false, false, true}, {false, false, false}}},
+		{"mouse button", []func(input *Input){
+			func(input *Input) { input.InjectButton("a", true) },
+			func(input *Input) { input.InjectButton("a", false) },
+		}, []keyState{{true, true, false}, {false, false, true}}},
+		{"other key", []func(input *Input){
+			func(input *Input) { input.InjectKey("b", true) },
+		}, []keyState{{false, false, false}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			frames := []inputFrame{}
+			for n := range test.inject {
+				n, want := n, test.want[n]
+				frames = append(frames, inputFrame{test.inject[n], func(t *testing.T, input *Input) {
+					got := keyState{input.GetKey("a"), input.GetKeyDown("a"), input.GetKeyUp("a")}
+					if got != want {
+						t.Errorf("frame %d: held, down, up = %v, want %v", n, got, want)
+					}
+				}})
+			}
+			runInputFrames(t, newInput(), frames)
+		})
+	}
+}
+
+func TestGetKeyAxis(t *testing.T) {
+	press := func(key string, down bool) func(input *Input) {
+		return func(input *Input) { input.InjectKey(key, down) }
+	}
+	tests := []struct {
+		name   string
+		inject []func(input *Input)
+		want   []float64
+	}{
+		// held keys move by 0.5 and released ones fall back by 0.25 a frame
+		{"held", []func(input *Input){press("d", true), nil, nil, nil},
+			[]float64{0, 0.5, 1, 1}},
+		{"released", []func(input *Input){press("d", true), nil, press("d", false), nil, nil, nil, nil},
+			[]float64{0, 0.5, 0.25, 0, 0, 0, 0}},
+		{"clamped before falling back", []func(input *Input){press("d", true), nil, nil, nil, press("d", false)},
+			[]float64{0, 0.5, 1, 1, 0.75}},
+		{"negative", []func(input *Input){press("a", true), nil, nil, press("a", false)},
+			[]float64{0, -0.5, -1, -0.75}},
+		{"both held", []func(input *Input){press("d", true), nil, press("a", true), nil},
+			[]float64{0, 0.5, 1, 0.75}},
+		// reversing snaps to zero rather than falling back first
+		{"snapped on reversal", []func(input *Input){
+			press("d", true), nil, nil,
+			func(input *Input) { input.InjectKey("d", false); input.InjectKey("a", true) },
+			nil,
+		}, []float64{0, 0.5, 1, 0.75, -0.5}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := newInput()
+			input.BindAxis("move", AxisMeta{Source: AXIS_SOURCE_KEY, PositiveKey: "d", NegativeKey: "a", Gravity: 2, Sensitivity: 4})
+			frames := []inputFrame{}
+			for n := range test.inject {
+				n, want := n, test.want[n]
+				frames = append(frames, inputFrame{test.inject[n], func(t *testing.T, input *Input) {
+					if got := input.GetAxis("move"); math.Abs(got-want) > 1e-9 {
+						t.Errorf("frame %d: axis = %v, want %v", n, got, want)
+					}
+				}})
+			}
+			runInputFrames(t, input, frames)
+		})
+	}
+}
+
+func TestGetMouseAxis(t *testing.T) {
+	input := newInput()
+	input.BindAxis("look", AxisMeta{Source: AXIS_SOURCE_MOUSE, From: AXIS_FROM_X, Sensitivity: 0.5})
+	input.BindAxis("look", AxisMeta{Source: AXIS_SOURCE_MOUSE, From: AXIS_FROM_Y, Sensitivity: 1, Dead: 2})
+	moves := [][2]float64{{10, 1}, {30, 1}, {30, 1}, {28, 31}}
+	// the binding moving furthest wins, y moving 1 is in its dead zone
+	want := []float64{5, 10, 0, 30}
+	for n, move := range moves {
+		input.InjectMouseMove(move[0], move[1])
+		input.frameStart(1 + float64(n)/8)
+		if got := input.GetAxis("look"); math.Abs(got-want[n]) > 1e-9 {
+			t.Errorf("frame %d: axis = %v, want %v", n, got, want[n])
+		}
+		input.frameEnd()
+	}
+
+	input.ResetAxis("look")
+	input.InjectMouseMove(0, 0)
+	input.frameStart(2)
+	if got := input.GetAxis("look"); got != 0 {
+		t.Errorf("reset axis = %v", got)
+	}
+}
+
+func TestTypedTextAndScroll(t *testing.T) {
+	input := newInput()
+	input.BindAxis("zoom", AxisMeta{Source: AXIS_SOURCE_SCROLL, From: AXIS_FROM_Y, Sensitivity: 2})
+	input.BindAxis("pan", AxisMeta{Source: AXIS_SOURCE_SCROLL, From: AXIS_FROM_X, Sensitivity: 1, Dead: 0.5})
+	frames := []struct {
+		text              []string
+		scroll            [][2]float64
+		wantText          string
+		wantX, wantY      float64
+		wantZoom, wantPan float64
+	}{
+		{[]string{"h", "é"}, [][2]float64{{0, 1}, {0.25, 0.5}}, "hé", 0.25, 1.5, 3, 0},
+		{nil, nil, "", 0, 0, 0, 0},
+		{[]string{"llo"}, [][2]float64{{-2, 0}}, "llo", -2, 0, 0, -2},
+	}
+	for n, frame := range frames {
+		for _, text := range frame.text {
+			input.InjectText(text)
+		}
+		for _, scroll := range frame.scroll {
+			input.InjectScroll(scroll[0], scroll[1])
+		}
+		input.frameStart(1 + float64(n)/8)
+		if got := input.TypedText(); got != frame.wantText {
+			t.Errorf("frame %d: text = %q, want %q", n, got, frame.wantText)
+		}
+		if x, y := input.ScrollDelta(); x != frame.wantX || y != frame.wantY {
+			t.Errorf("frame %d: scroll = %v, %v, want %v, %v", n, x, y, frame.wantX, frame.wantY)
+		}
+		if got := input.GetAxis("zoom"); got != frame.wantZoom {
+			t.Errorf("frame %d: zoom = %v, want %v", n, got, frame.wantZoom)
+		}
+		if got := input.GetAxis("pan"); got != frame.wantPan {
+			t.Errorf("frame %d: pan = %v, want %v", n, got, frame.wantPan)
+		}
+		input.frameEnd()
+	}
+}
+
+func TestNormalizedMousePosition(t *testing.T) {
+	tests := []struct {
+		name         string
+		width        float64
+		height       float64
+		x, y         float64
+		wantX, wantY float64
+	}{
+		{"top left", 800, 600, 0, 0, 0, 1},
+		{"bottom right", 800, 600, 800, 600, 1, 0},
+		{"middle", 800, 600, 400, 150, 0.5, 0.75},
+		{"outside", 800, 600, -80, 660, -0.1, -0.1},
+		{"no window size", 0, 0, 400, 300, 0, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := newInput()
+			if test.width > 0 {
+				input.InjectWindowSize(test.width, test.height)
+			}
+			input.InjectMouseMove(test.x, test.y)
+			input.frameStart(1)
+			x, y := input.NormalizedMousePosition()
+			if math.Abs(x-test.wantX) > 1e-9 || math.Abs(y-test.wantY) > 1e-9 {
+				t.Errorf("position = %v, %v, want %v, %v", x, y, test.wantX, test.wantY)
+			}
+			if x, y := input.MousePosition(); x != test.x || y != test.y {
+				t.Errorf("window position = %v, %v, want %v, %v", x, y, test.x, test.y)
+			}
+		})
+	}
+}
+
+func TestMouseEnterLeave(t *testing.T) {
+	// inside, entered and left in each frame
+	type mouseState struct{ inside, entered, left bool }
+	events := [][]bool{{true}, nil, {false}, nil, {false, true}, {true, false}}
+	want := []mouseState{
+		{true, true, false},
+		{true, false, false},
+		{false, false, true},
+		{false, false, false},
+		{true, true, false},
+		{false, false, true},
+	}
+	input := newInput()
+	for n, entered := range events {
+		for _, e := range entered {
+			input.InjectMouseEnter(e)
+		}
+		input.frameStart(1 + float64(n)/8)
+		got := mouseState{input.MouseInside(), input.MouseEntered(), input.MouseLeft()}
+		if got != want[n] {
+			t.Errorf("frame %d: inside, entered, left = %v, want %v", n, got, want[n])
+		}
+		input.frameEnd()
+	}
+}
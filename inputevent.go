@@ -0,0 +1,142 @@
+package wengine
+
+import "sync"
+
+const (
+	INPUT_EVENT_KEY = iota
+	INPUT_EVENT_BUTTON
+	INPUT_EVENT_MOUSE_MOVE
+	INPUT_EVENT_SCROLL
+	INPUT_EVENT_TEXT
+	INPUT_EVENT_JOYSTICK_CONNECT
+	INPUT_EVENT_JOYSTICK_DISCONNECT
+	INPUT_EVENT_JOYSTICK_AXIS
+	INPUT_EVENT_JOYSTICK_BUTTON
+	INPUT_EVENT_MOUSE_ENTER
+	INPUT_EVENT_MOUSE_LEAVE
+	INPUT_EVENT_WINDOW_SIZE
+)
+
+// InputEvent is a platform-independent input event. Platform backends such
+// as App, tests and bots feed them to Input, which applies them at the start
+// of the next frame in the order they were injected.
+type InputEvent struct {
+	Type int
+
+	// key & button, named as in GetKey
+	Key   string
+	State int
+
+	// mouse move: cursor position, scroll: offset, window size: width and
+	// height, joystick axis: value in X
+	X, Y float64
+
+	// text, joystick name on connect
+	Text string
+
+	// joystick: device number from 1, axis or button index
+	Device  int
+	Axis    int
+	Gamepad bool
+}
+
+type inputQueue struct {
+	lock   sync.Mutex
+	events []InputEvent
+}
+
+func (q *inputQueue) push(event InputEvent) {
+	q.lock.Lock()
+	q.events = append(q.events, event)
+	q.lock.Unlock()
+}
+
+func (q *inputQueue) take() []InputEvent {
+	q.lock.Lock()
+	events := q.events
+	q.events = nil
+	q.lock.Unlock()
+	return events
+}
+
+// Inject queues an event for the next frame. It is safe to call from any
+// goroutine.
+func (i *Input) Inject(event InputEvent) {
+	i.queue.push(event)
+}
+
+func (i *Input) InjectKey(key string, down bool) {
+	i.Inject(InputEvent{Type: INPUT_EVENT_KEY, Key: key, State: keyState(down)})
+}
+
+// InjectButton presses or releases a mouse button, named "mouse 1" to
+// "mouse 8".
+func (i *Input) InjectButton(button string, down bool) {
+	i.Inject(InputEvent{Type: INPUT_EVENT_BUTTON, Key: button, State: keyState(down)})
+}
+
+// InjectMouseMove moves the cursor to x, y in window coordinates.
+func (i *Input) InjectMouseMove(x, y float64) {
+	i.Inject(InputEvent{Type: INPUT_EVENT_MOUSE_MOVE, X: x, Y: y})
+}
+
+func (i *Input) InjectScroll(xOffset, yOffset float64) {
+	i.Inject(InputEvent{Type: INPUT_EVENT_SCROLL, X: xOffset, Y: yOffset})
+}
+
+func (i *Input) InjectText(text string) {
+	i.Inject(InputEvent{Type: INPUT_EVENT_TEXT, Text: text})
+}
+
+// InjectMouseEnter moves the cursor into or out of the window.
+func (i *Input) InjectMouseEnter(entered bool) {
+	if entered {
+		i.Inject(InputEvent{Type: INPUT_EVENT_MOUSE_ENTER})
+	} else {
+		i.Inject(InputEvent{Type: INPUT_EVENT_MOUSE_LEAVE})
+	}
+}
+
+// InjectWindowSize sets the window size in window coordinates, which
+// NormalizedMousePosition is relative to.
+func (i *Input) InjectWindowSize(width, height float64) {
+	i.Inject(InputEvent{Type: INPUT_EVENT_WINDOW_SIZE, X: width, Y: height})
+}
+
+// Events returns the events applied in the current frame.
+func (i *Input) Events() []InputEvent {
+	return i.frameEvents
+}
+
+func keyState(down bool) int {
+	if down {
+		return KEY_STATE_DOWN
+	}
+	return KEY_STATE_UP
+}
+
+// applyEvents applies the queued events to the current state.
+func (i *Input) applyEvents() {
+	i.frameEvents = i.queue.take()
+	for _, event := range i.frameEvents {
+		switch event.Type {
+		case INPUT_EVENT_KEY, INPUT_EVENT_BUTTON:
+			i.curKeyState[event.Key] = event.State
+		case INPUT_EVENT_MOUSE_MOVE:
+			i.curMouseX, i.curMouseY = event.X, event.Y
+		case INPUT_EVENT_SCROLL:
+			i.scrollX += event.X
+			i.scrollY += event.Y
+		case INPUT_EVENT_TEXT:
+			i.text += event.Text
+		case INPUT_EVENT_MOUSE_ENTER:
+			i.curMouseInside = true
+		case INPUT_EVENT_MOUSE_LEAVE:
+			i.curMouseInside = false
+		case INPUT_EVENT_WINDOW_SIZE:
+			i.windowWidth, i.windowHeight = event.X, event.Y
+		default:
+			i.applyJoystickEvent(event)
+		}
+	}
+}
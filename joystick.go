@@ -0,0 +1,210 @@
+package wengine
+
+import (
+	"math"
+	"sort"
+	"strconv"
+)
+
+// Gamepad buttons in the standard layout, named in GetKey as
+// "gamepad <device> <name>", e.g. "gamepad 1 a" or "gamepad 2 dpad up".
+// Buttons of joysticks without a gamepad mapping are named
+// "joystick <device> button <index>".
+const (
+	GAMEPAD_BUTTON_A = iota
+	GAMEPAD_BUTTON_B
+	GAMEPAD_BUTTON_X
+	GAMEPAD_BUTTON_Y
+	GAMEPAD_BUTTON_LEFT_BUMPER
+	GAMEPAD_BUTTON_RIGHT_BUMPER
+	GAMEPAD_BUTTON_BACK
+	GAMEPAD_BUTTON_START
+	GAMEPAD_BUTTON_GUIDE
+	GAMEPAD_BUTTON_LEFT_THUMB
+	GAMEPAD_BUTTON_RIGHT_THUMB
+	GAMEPAD_BUTTON_DPAD_UP
+	GAMEPAD_BUTTON_DPAD_RIGHT
+	GAMEPAD_BUTTON_DPAD_DOWN
+	GAMEPAD_BUTTON_DPAD_LEFT
+)
+
+var gamepadButtonNames = []string{
+	GAMEPAD_BUTTON_A:            "a",
+	GAMEPAD_BUTTON_B:            "b",
+	GAMEPAD_BUTTON_X:            "x",
+	GAMEPAD_BUTTON_Y:            "y",
+	GAMEPAD_BUTTON_LEFT_BUMPER:  "left bumper",
+	GAMEPAD_BUTTON_RIGHT_BUMPER: "right bumper",
+	GAMEPAD_BUTTON_BACK:         "back",
+	GAMEPAD_BUTTON_START:        "start",
+	GAMEPAD_BUTTON_GUIDE:        "guide",
+	GAMEPAD_BUTTON_LEFT_THUMB:   "left thumb",
+	GAMEPAD_BUTTON_RIGHT_THUMB:  "right thumb",
+	GAMEPAD_BUTTON_DPAD_UP:      "dpad up",
+	GAMEPAD_BUTTON_DPAD_RIGHT:   "dpad right",
+	GAMEPAD_BUTTON_DPAD_DOWN:    "dpad down",
+	GAMEPAD_BUTTON_DPAD_LEFT:    "dpad left",
+}
+
+// MAX_JOYSTICKS is the number of joystick devices, numbered from 1.
+const MAX_JOYSTICKS = 16
+
+type joystickState struct {
+	name    string
+	gamepad bool
+	// indexed by AXIS_FROM_* - AXIS_FROM_LEFT_X
+	axes []float64
+}
+
+// JoystickButtonKey returns the name of a button of a device in GetKey.
+func (i *Input) JoystickButtonKey(device int, button int) string {
+	if joystick := i.joysticks[device]; joystick != nil && joystick.gamepad && button >= 0 && button < len(gamepadButtonNames) {
+		return "gamepad " + strconv.Itoa(device) + " " + gamepadButtonNames[button]
+	}
+	return "joystick " + strconv.Itoa(device) + " button " + strconv.Itoa(button)
+}
+
+// Joysticks returns the connected devices in increasing order.
+func (i *Input) Joysticks() []int {
+	devices := []int{}
+	for device := range i.joysticks {
+		devices = append(devices, device)
+	}
+	sort.Ints(devices)
+	return devices
+}
+
+func (i *Input) JoystickName(device int) string {
+	if joystick := i.joysticks[device]; joystick != nil {
+		return joystick.name
+	}
+	return ""
+}
+
+// IsGamepad reports whether the device has a standard gamepad mapping.
+func (i *Input) IsGamepad(device int) bool {
+	if joystick := i.joysticks[device]; joystick != nil {
+		return joystick.gamepad
+	}
+	return false
+}
+
+// GetJoystickAxis returns the raw value of an axis of a device, from -1 to 1
+// for sticks and 0 to 1 for triggers. axis is one of AXIS_FROM_LEFT_X to
+// AXIS_FROM_RIGHT_TRIGGER, or further for joysticks with more axes.
+func (i *Input) GetJoystickAxis(device int, axis int) float64 {
+	joystick := i.joysticks[device]
+	index := axis - AXIS_FROM_LEFT_X
+	if joystick == nil || index < 0 || index >= len(joystick.axes) {
+		return 0
+	}
+	return joystick.axes[index]
+}
+
+func (i *Input) InjectJoystickConnect(device int, name string, gamepad bool) {
+	i.Inject(InputEvent{Type: INPUT_EVENT_JOYSTICK_CONNECT, Device: device, Text: name, Gamepad: gamepad})
+}
+
+func (i *Input) InjectJoystickDisconnect(device int) {
+	i.Inject(InputEvent{Type: INPUT_EVENT_JOYSTICK_DISCONNECT, Device: device})
+}
+
+// InjectJoystickAxis sets an axis of a device, see GetJoystickAxis.
+func (i *Input) InjectJoystickAxis(device int, axis int, value float64) {
+	i.Inject(InputEvent{Type: INPUT_EVENT_JOYSTICK_AXIS, Device: device, Axis: axis, X: value})
+}
+
+// InjectJoystickButton presses or releases a button of a device, one of
+// GAMEPAD_BUTTON_* for gamepads.
+func (i *Input) InjectJoystickButton(device int, button int, down bool) {
+	i.Inject(InputEvent{Type: INPUT_EVENT_JOYSTICK_BUTTON, Device: device, Axis: button, State: keyState(down)})
+}
+
+func (i *Input) applyJoystickEvent(event InputEvent) {
+	switch event.Type {
+	case INPUT_EVENT_JOYSTICK_CONNECT:
+		i.joysticks[event.Device] = &joystickState{name: event.Text, gamepad: event.Gamepad}
+	case INPUT_EVENT_JOYSTICK_DISCONNECT:
+		if i.joysticks[event.Device] == nil {
+			return
+		}
+		// release what was held on the device
+		for button := 0; button < len(gamepadButtonNames); button++ {
+			i.curKeyState[i.JoystickButtonKey(event.Device, button)] = KEY_STATE_UP
+		}
+		prefix := "joystick " + strconv.Itoa(event.Device) + " button "
+		for key := range i.curKeyState {
+			if len(key) > len(prefix) && key[:len(prefix)] == prefix {
+				i.curKeyState[key] = KEY_STATE_UP
+			}
+		}
+		delete(i.joysticks, event.Device)
+	case INPUT_EVENT_JOYSTICK_AXIS:
+		if joystick := i.joysticks[event.Device]; joystick != nil {
+			joystick.setAxis(event.Axis, event.X)
+		}
+	case INPUT_EVENT_JOYSTICK_BUTTON:
+		if i.joysticks[event.Device] == nil {
+			return
+		}
+		i.curKeyState[i.JoystickButtonKey(event.Device, event.Axis)] = event.State
+	}
+}
+
+// replaceJoysticks releases what is held on the connected devices and
+// replaces them, when switching between live and replayed devices.
+func (i *Input) replaceJoysticks(joysticks map[int]*joystickState) {
+	for _, device := range i.Joysticks() {
+		i.applyJoystickEvent(InputEvent{Type: INPUT_EVENT_JOYSTICK_DISCONNECT, Device: device})
+	}
+	i.joysticks = joysticks
+}
+
+func (j *joystickState) setAxis(axis int, value float64) {
+	index := axis - AXIS_FROM_LEFT_X
+	if index < 0 {
+		return
+	}
+	for len(j.axes) <= index {
+		j.axes = append(j.axes, 0)
+	}
+	j.axes[index] = value
+}
+
+// joystickAxisValue is the value of a joystick axis binding, taken from the
+// device of the binding, or from the connected device pushing the axis
+// furthest when the binding has no device. The dead zone applies to the raw
+// value, which is rescaled so that the axis still reaches 1 past it, before
+// the sensitivity.
+func (i *Input) joystickAxisValue(meta *AxisMeta) float64 {
+	axis := meta.From
+	// X and Y mean the left stick for joysticks
+	switch axis {
+	case AXIS_FROM_X:
+		axis = AXIS_FROM_LEFT_X
+	case AXIS_FROM_Y:
+		axis = AXIS_FROM_LEFT_Y
+	}
+
+	var raw float64
+	if meta.Joystick != 0 {
+		raw = i.GetJoystickAxis(meta.Joystick, axis)
+	} else {
+		for _, device := range i.Joysticks() {
+			if value := i.GetJoystickAxis(device, axis); math.Abs(value) > math.Abs(raw) {
+				raw = value
+			}
+		}
+	}
+	if meta.Invert {
+		raw = -raw
+	}
+	magnitude := math.Abs(raw)
+	if magnitude <= meta.Dead {
+		return 0
+	}
+	if meta.Dead > 0 && meta.Dead < 1 {
+		magnitude = (magnitude - meta.Dead) / (1 - meta.Dead)
+	}
+	return meta.Sensitivity * math.Copysign(magnitude, raw)
+}
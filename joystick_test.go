@@ -0,0 +1,64 @@
+package wengine
+
+import (
+	"math"
+	"testing"
+)
+
+func TestJoystickAxisValue(t *testing.T) {
+	tests := []struct {
+		name string
+		meta AxisMeta
+		// axes holds the left stick x of devices 1 and 2
+		axes [2]float64
+		want float64
+	}{
+		{"inside the dead zone", AxisMeta{Dead: 0.2, Sensitivity: 1}, [2]float64{0.1, 0}, 0},
+		{"at the dead zone", AxisMeta{Dead: 0.2, Sensitivity: 1}, [2]float64{0.2, 0}, 0},
+		{"past the dead zone", AxisMeta{Dead: 0.2, Sensitivity: 1}, [2]float64{0.6, 0}, 0.5},
+		{"full past the dead zone", AxisMeta{Dead: 0.2, Sensitivity: 1}, [2]float64{-1, 0}, -1},
+		{"dead zone before sensitivity", AxisMeta{Dead: 0.2, Sensitivity: 0.1}, [2]float64{0.6, 0}, 0.05},
+		{"sensitivity", AxisMeta{Sensitivity: 2}, [2]float64{0.25, 0}, 0.5},
+		{"inverted", AxisMeta{Dead: 0.5, Sensitivity: 1, Invert: true}, [2]float64{0.75, 0}, -0.5},
+		{"furthest device", AxisMeta{Sensitivity: 1}, [2]float64{0.3, -0.4}, -0.4},
+		{"tie goes to the first device", AxisMeta{Sensitivity: 1}, [2]float64{0.4, -0.4}, 0.4},
+		{"bound device", AxisMeta{Joystick: 2, Sensitivity: 1}, [2]float64{0.9, 0.1}, 0.1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			input := newInput()
+			meta := test.meta
+			meta.Source = AXIS_SOURCE_JOYSTICK
+			meta.From = AXIS_FROM_LEFT_X
+			input.BindAxis("axis", meta)
+			for i, value := range test.axes {
+				input.InjectJoystickConnect(i+1, "pad", true)
+				input.InjectJoystickAxis(i+1, AXIS_FROM_LEFT_X, value)
+			}
+			input.frameStart(1)
+			if got := input.GetAxis("axis"); math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("axis = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestJoystickDisconnectReleases(t *testing.T) {
+	input := newInput()
+	input.InjectJoystickConnect(1, "pad", true)
+	input.InjectJoystickButton(1, GAMEPAD_BUTTON_A, true)
+	input.frameStart(1)
+	key := input.JoystickButtonKey(1, GAMEPAD_BUTTON_A)
+	if !input.GetKeyDown(key) {
+		t.Fatalf("%q not down", key)
+	}
+	input.frameEnd()
+	input.InjectJoystickDisconnect(1)
+	input.frameStart(2)
+	if input.GetKey(key) || !input.GetKeyUp(key) {
+		t.Errorf("%q still held after disconnect", key)
+	}
+	if devices := input.Joysticks(); len(devices) != 0 {
+		t.Errorf("joysticks = %v", devices)
+	}
+}
@@ -0,0 +1,149 @@
+package wengine
+
+// NullFrame is what a NullRenderer was asked to draw in one frame. Cameras
+// are sorted in the order they would be rendered.
+type NullFrame struct {
+	Cameras []*CameraComponent
+	Meshes  []*MeshComponent
+	Lights  []*LightComponent
+	Sprites []*SpriteComponent
+}
+
+// NullRenderer draws nothing and only records what it was asked to draw, so
+// that scenes can be checked without a GPU.
+type NullRenderer struct {
+	context *Context
+
+	frame      NullFrame
+	frameCount int
+	installed  []string
+}
+
+func NewNullRenderer() *NullRenderer {
+	return &NullRenderer{}
+}
+
+func (r *NullRenderer) Init(context *Context) error {
+	r.context = context
+	r.frame = NullFrame{}
+	r.frameCount = 0
+	r.installed = nil
+	return nil
+}
+
+func (r *NullRenderer) Version() string {
+	return "null"
+}
+
+func (r *NullRenderer) Render(scene *Scene) error {
+	frame := NullFrame{}
+	for _, obj := range scene.Objects() {
+		if !obj.ActiveInHierarchy() {
+			continue
+		}
+		for _, compos := range obj.Components() {
+			for _, compo := range compos {
+				switch c := compo.(type) {
+				case *CameraComponent:
+					frame.Cameras = append(frame.Cameras, c)
+				case *MeshComponent:
+					frame.Meshes = append(frame.Meshes, c)
+				case *LightComponent:
+					frame.Lights = append(frame.Lights, c)
+				case *SpriteComponent:
+					frame.Sprites = append(frame.Sprites, c)
+				}
+			}
+		}
+	}
+	// same order as the opengl renderer
+	SortCameras(frame.Cameras, frame.Meshes, frame.Sprites, r.context.Assets())
+	r.frame = frame
+	r.frameCount++
+	return nil
+}
+
+func (r *NullRenderer) NotifyInstall(assets []string) error {
+	r.installed = append(r.installed, assets...)
+	return nil
+}
+
+// LastFrame returns what was asked to be drawn by the latest Render.
+func (r *NullRenderer) LastFrame() NullFrame {
+	return r.frame
+}
+
+func (r *NullRenderer) FrameCount() int {
+	return r.frameCount
+}
+
+// Installed lists the assets the renderer was notified of, in order.
+func (r *NullRenderer) Installed() []string {
+	return r.installed
+}
+
+// MeshVisible reports whether mesh was drawn in the last frame and its
+// bounding box intersects the view frustum of camera. The mesh asset must
+// be loaded.
+func (r *NullRenderer) MeshVisible(camera *CameraComponent, mesh *MeshComponent) bool {
+	drawn := false
+	for _, m := range r.frame.Meshes {
+		if m == mesh {
+			drawn = true
+			break
+		}
+	}
+	if !drawn {
+		return false
+	}
+	asset, ok := r.context.Assets()[mesh.Mesh].(*MeshAsset)
+	if !ok || len(asset.Vertices) == 0 {
+		return false
+	}
+
+	min, max := asset.Vertices[0], asset.Vertices[0]
+	for _, v := range asset.Vertices {
+		for i := 0; i < 3; i++ {
+			if v[i] < min[i] {
+				min[i] = v[i]
+			}
+			if v[i] > max[i] {
+				max[i] = v[i]
+			}
+		}
+	}
+
+	scrWidth, scrHeight := r.context.TargetSize(camera)
+	if scrWidth == 0 || scrHeight == 0 {
+		scrWidth, scrHeight = 1, 1
+	}
+	mvp := camera.ProjectionMatrix(scrWidth, scrHeight).Mul4(camera.ViewMatrix()).Mul4(mesh.Object().ModelMatrix())
+
+	// the box is invisible if all of its corners are outside of the same
+	// frustum plane
+	var outside [6]int
+	for i := 0; i < 8; i++ {
+		corner := min
+		for axis := 0; axis < 3; axis++ {
+			if i&(1<<uint(axis)) != 0 {
+				corner[axis] = max[axis]
+			}
+		}
+		clip := mvp.Mul4x1(corner.Vec4(1))
+		w := clip.W()
+		for axis := 0; axis < 3; axis++ {
+			if clip[axis] < -w {
+				outside[axis*2]++
+			}
+			if clip[axis] > w {
+				outside[axis*2+1]++
+			}
+		}
+	}
+	for _, n := range outside {
+		if n == 8 {
+			return false
+		}
+	}
+	return true
+}
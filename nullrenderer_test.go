@@ -0,0 +1,203 @@
+package wengine
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"testing"
+)
+
+// newNullScene makes a context with the null renderer, a camera at the
+// origin looking down -z and a cube mesh.
+func newNullScene(t *testing.T) (ctx *Context, camera *CameraComponent, mesh *MeshComponent) {
+	ctx = NewContext("null")
+	if ctx.RendererName() != "null" {
+		t.Fatal("null renderer not selected")
+	}
+	ctx.SetScreenSize(800, 600)
+	ctx.RegisterAsset("cube", DefaultCubeMeshAsset())
+	scene := NewScene()
+
+	camera = &CameraComponent{Mode: CAMERA_MODE_PERSPECTIVE, FOV: mgl32.DegToRad(60), NearPlane: 0.1, FarPlane: 100, ViewportW: 1, ViewportH: 1}
+	cameraObject := NewObject()
+	cameraObject.AttachComponent(camera)
+	cameraObject.SetEnabled(true)
+	scene.RegisterObject("camera", cameraObject)
+
+	mesh = &MeshComponent{Mesh: "cube"}
+	meshObject := NewObject()
+	meshObject.AttachComponent(mesh)
+	meshObject.SetEnabled(true)
+	scene.RegisterObject("cube", meshObject)
+
+	ctx.RegisterScene("scene", scene)
+	ctx.ApplyScene("scene")
+	return
+}
+
+func TestNullRendererMeshVisible(t *testing.T) {
+	tests := []struct {
+		name     string
+		position mgl32.Vec3
+		disabled bool
+		want     bool
+	}{
+		{"in front", mgl32.Vec3{0, 0, -10}, false, true},
+		{"behind", mgl32.Vec3{0, 0, 10}, false, false},
+		{"far left", mgl32.Vec3{-50, 0, -10}, false, false},
+		{"beyond the far plane", mgl32.Vec3{0, 0, -200}, false, false},
+		{"partly in view", mgl32.Vec3{6.5, 0, -10}, false, true},
+		{"disabled", mgl32.Vec3{0, 0, -10}, true, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, camera, mesh := newNullScene(t)
+			mesh.Object().SetPosition(test.position)
+			mesh.Object().SetEnabled(!test.disabled)
+			if err := ctx.Step(1.0 / 60); err != nil {
+				t.Fatal(err)
+			}
+			renderer := ctx.Renderer().(*NullRenderer)
+			if got := renderer.MeshVisible(camera, mesh); got != test.want {
+				t.Errorf("visible = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMeshVisibleFrustum(t *testing.T) {
+	// a camera at the origin looking down -z, seeing 5 units either side at
+	// 5 units away on a square screen
+	box := &MeshAsset{Vertices: []mgl32.Vec3{{-0.5, -0.5, -0.5}, {0.5, 0.5, 0.5}}}
+	tests := []struct {
+		name     string
+		position mgl32.Vec3
+		scale    float32
+		ortho    bool
+		// target is a render texture twice as wide as high if set
+		target bool
+		yaw    float32
+		want   bool
+	}{
+		{"inside", mgl32.Vec3{0, 0, -5}, 1, false, false, 0, true},
+		{"straddling left", mgl32.Vec3{-5, 0, -5}, 1, false, false, 0, true},
+		{"outside left", mgl32.Vec3{-6.5, 0, -5}, 1, false, false, 0, false},
+		{"outside right", mgl32.Vec3{6.5, 0, -5}, 1, false, false, 0, false},
+		{"straddling top", mgl32.Vec3{0, 5, -5}, 1, false, false, 0, true},
+		{"outside bottom", mgl32.Vec3{0, -6.5, -5}, 1, false, false, 0, false},
+		{"straddling near", mgl32.Vec3{0, 0, -1}, 1, false, false, 0, true},
+		{"before near", mgl32.Vec3{0, 0, -0.25}, 1, false, false, 0, false},
+		{"behind", mgl32.Vec3{0, 0, 5}, 1, false, false, 0, false},
+		{"straddling far", mgl32.Vec3{0, 0, -10}, 1, false, false, 0, true},
+		{"beyond far", mgl32.Vec3{0, 0, -11}, 1, false, false, 0, false},
+		{"around the frustum", mgl32.Vec3{0, 0, -5}, 100, false, false, 0, true},
+		{"orthographic inside", mgl32.Vec3{0, 0, -5}, 1, true, false, 0, true},
+		{"orthographic straddling", mgl32.Vec3{5, 0, -5}, 1, true, false, 0, true},
+		{"orthographic outside", mgl32.Vec3{6, 0, -5}, 1, true, false, 0, false},
+		{"wide target", mgl32.Vec3{9, 0, -5}, 1, false, true, 0, true},
+		{"wide target outside", mgl32.Vec3{0, 6.5, -5}, 1, false, true, 0, false},
+		{"turned camera", mgl32.Vec3{-5, 0, 0}, 1, false, false, 90, true},
+		{"turned camera outside", mgl32.Vec3{0, 0, -5}, 1, false, false, 90, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := NewHeadlessContext()
+			ctx.SetScreenSize(100, 100)
+			ctx.RegisterAsset("box", box)
+			ctx.RegisterAsset("wide", &RenderTextureAsset{Width: 200, Height: 100})
+
+			camera := &CameraComponent{Mode: CAMERA_MODE_PERSPECTIVE, FOV: mgl32.DegToRad(90), NearPlane: 1, FarPlane: 10, Width: 10, ViewportW: 1, ViewportH: 1}
+			if test.ortho {
+				camera.Mode = CAMERA_MODE_ORTHOGRAPHIC
+			}
+			if test.target {
+				camera.TargetTexture = "wide"
+			}
+			cameraObject := NewObject()
+			cameraObject.AttachComponent(camera)
+			cameraObject.Rotate(mgl32.DegToRad(test.yaw), mgl32.Vec3{0, 1, 0})
+			cameraObject.updateTransform()
+
+			mesh := &MeshComponent{Mesh: "box"}
+			meshObject := NewObject()
+			meshObject.AttachComponent(mesh)
+			meshObject.SetPosition(test.position)
+			meshObject.SetLocalScale(mgl32.Vec3{test.scale, test.scale, test.scale})
+			meshObject.updateTransform()
+
+			renderer := NewNullRenderer()
+			renderer.Init(ctx)
+			renderer.frame.Meshes = []*MeshComponent{mesh}
+			if got := renderer.MeshVisible(camera, mesh); got != test.want {
+				t.Errorf("visible = %v, want %v", got, test.want)
+			}
+
+			renderer.frame.Meshes = nil
+			if renderer.MeshVisible(camera, mesh) {
+				t.Error("visible while not drawn")
+			}
+		})
+	}
+}
+
+func TestNullRendererPerContext(t *testing.T) {
+	first, _, _ := newNullScene(t)
+	second, _, _ := newNullScene(t)
+	if first.Renderer() == second.Renderer() {
+		t.Fatal("contexts share a renderer")
+	}
+	for i := 0; i < 3; i++ {
+		if err := first.Step(1.0 / 60); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := second.Step(1.0 / 60); err != nil {
+		t.Fatal(err)
+	}
+	if n := first.Renderer().(*NullRenderer).FrameCount(); n != 3 {
+		t.Errorf("first renderer drew %d frames, want 3", n)
+	}
+	if n := second.Renderer().(*NullRenderer).FrameCount(); n != 1 {
+		t.Errorf("second renderer drew %d frames, want 1", n)
+	}
+	frame := first.Renderer().(*NullRenderer).LastFrame()
+	if len(frame.Cameras) != 1 || len(frame.Meshes) != 1 {
+		t.Errorf("frame = %+v", frame)
+	}
+	if installed := first.Renderer().(*NullRenderer).Installed(); len(installed) != 1 || installed[0] != "cube" {
+		t.Errorf("installed = %v", installed)
+	}
+}
+
+func TestHeadlessContextStep(t *testing.T) {
+	ctx := NewHeadlessContext()
+	if ctx.Renderer() != nil {
+		t.Fatal("headless context has a renderer")
+	}
+	if err := ctx.Step(1.0 / 60); err == nil {
+		t.Error("stepped without a scene")
+	}
+	ctx.RegisterScene("scene", NewScene())
+	ctx.ApplyScene("scene")
+	for i := 0; i < 2; i++ {
+		if err := ctx.Step(1.0 / 60); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ctx.FrameCount() != 2 {
+		t.Errorf("frame count = %d, want 2", ctx.FrameCount())
+	}
+}
+
+func TestRegisterRenderer(t *testing.T) {
+	shared := NewNullRenderer()
+	RegisterRenderer("test.shared", shared)
+	RegisterRendererFactory("test.factory", func() Renderer { return NewNullRenderer() })
+	defer delete(registeredRenderers, "test.shared")
+	defer delete(registeredRenderers, "test.factory")
+
+	if first, second := NewContext("test.shared"), NewContext("test.shared"); first.Renderer() != shared || second.Renderer() != shared {
+		t.Error("registered renderer not shared")
+	}
+	if first, second := NewContext("test.factory"), NewContext("test.factory"); first.Renderer() == second.Renderer() {
+		t.Error("factory renderer shared")
+	}
+}
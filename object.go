@@ -1,57 +1,216 @@
 package wengine
 
 import (
+	"errors"
 	"github.com/go-gl/mathgl/mgl32"
+	"math"
 )
 
-type ComponentMap map[int]Component
+// ComponentMap holds the components of an object by type, in the order they
+// were attached.
+type ComponentMap map[int][]Component
 
 type Object struct {
-	enabled  bool
+	name string
+
+	enabled   bool
+	destroyed bool
+	// activeInHierarchy caches whether the object and all of its ancestors
+	// are enabled
+	activeInHierarchy bool
+
+	// scene is the scene the object was registered in
+	scene *Scene
+
 	parent   *Object
 	children []*Object
 
-	translation, rotation, scale, model mgl32.Mat4
+	localPosition, localScale mgl32.Vec3
+	localRotation             mgl32.Quat
+
+	local, model mgl32.Mat4
+
+	// localDirty is set when the local position, rotation or scale changed.
+	// worldDirty is set when model needs rebuilding, and is always set
+	// on every descendant of a world-dirty object as well.
+	localDirty, worldDirty bool
 
 	TransformInfo
 
-	behavior Behavior
+	behaviors []*behaviorSlot
 
 	components ComponentMap
+	// attached lists the components of every type in the order they were
+	// attached
+	attached []Component
 }
 
 func NewObject() *Object {
-	return &Object{translation: mgl32.Ident4(), rotation: mgl32.Ident4(), scale: mgl32.Ident4(), components: make(ComponentMap)}
+	return &Object{
+		localScale:    mgl32.Vec3{1, 1, 1},
+		localRotation: mgl32.QuatIdent(),
+		local:         mgl32.Ident4(),
+		model:         mgl32.Ident4(),
+		localDirty:    true,
+		worldDirty:    true,
+		TransformInfo: TransformInfo{rotation: mgl32.QuatIdent()},
+		components:    make(ComponentMap),
+	}
+}
+
+// Name returns the name the object was last registered under.
+func (o *Object) Name() string {
+	return o.name
+}
+
+// Destroyed reports whether the object was marked by Scene.Destroy.
+func (o *Object) Destroyed() bool {
+	return o.destroyed
 }
 
 func (o *Object) Enabled() bool {
 	return o.enabled
 }
 
+// SetEnabled enables or disables the object. Behaviors of the object and of
+// its descendants whose active state changes are notified right away if the
+// scene is applied.
 func (o *Object) SetEnabled(enabled bool) {
 	o.enabled = enabled
+	o.updateActive()
+}
+
+// ActiveInHierarchy reports whether the object and all of its ancestors
+// are enabled.
+func (o *Object) ActiveInHierarchy() bool {
+	return o.activeInHierarchy
+}
+
+// updateActive recomputes activeInHierarchy, and that of the descendants if
+// it changed, notifying their behaviors.
+func (o *Object) updateActive() {
+	active := o.enabled && (o.parent == nil || o.parent.activeInHierarchy)
+	if active == o.activeInHierarchy {
+		return
+	}
+	o.activeInHierarchy = active
+	if o.scene != nil {
+		o.scene.notifyActive(o)
+	}
+	for _, child := range o.children {
+		child.updateActive()
+	}
 }
 
-func (o *Object) SetParent(parent *Object) {
+func (o *Object) Parent() *Object {
+	return o.parent
+}
+
+func (o *Object) Children() []*Object {
+	return o.children
+}
+
+// SetParent moves the object under parent, or to the root if parent is nil.
+// If keepWorldTransform is true, the local transform is adjusted so that the
+// object stays where it is in world space.
+func (o *Object) SetParent(parent *Object, keepWorldTransform bool) error {
+	if parent == o.parent {
+		return nil
+	}
+	if parent != nil && (parent == o || o.IsAncestorOf(parent)) {
+		return errors.New("cannot parent an object to itself or its descendant")
+	}
+
+	var model mgl32.Mat4
+	if keepWorldTransform {
+		o.updateTransform()
+		model = o.model
+	}
+
+	if o.parent != nil {
+		o.parent.removeChild(o)
+	}
 	o.parent = parent
-	for _, child := range parent.children {
-		if child == o {
+	if parent != nil {
+		parent.children = append(parent.children, o)
+	}
+
+	if keepWorldTransform {
+		if parent != nil {
+			parent.updateTransform()
+			model = parent.model.Inv().Mul4(model)
+		}
+		o.setLocalMatrix(model)
+	}
+	o.markWorldDirty()
+	o.updateActive()
+	return nil
+}
+
+// Detach moves the object to the root, keeping its world transform.
+func (o *Object) Detach() {
+	o.SetParent(nil, true)
+}
+
+func (o *Object) removeChild(child *Object) {
+	for i, c := range o.children {
+		if c == child {
+			o.children = append(o.children[:i], o.children[i+1:]...)
 			return
 		}
 	}
-	parent.children = append(parent.children, o)
+}
+
+func (o *Object) Root() *Object {
+	root := o
+	for root.parent != nil {
+		root = root.parent
+	}
+	return root
+}
+
+func (o *Object) IsAncestorOf(other *Object) bool {
+	for p := other.parent; p != nil; p = p.parent {
+		if p == o {
+			return true
+		}
+	}
+	return false
+}
+
+// Walk visits the object and its descendants depth-first, parents before
+// children. Returning false from fn skips the children of that object.
+func (o *Object) Walk(fn func(obj *Object) bool) {
+	if !fn(o) {
+		return
+	}
+	for _, child := range o.children {
+		child.Walk(fn)
+	}
+}
+
+// Descendants returns all objects below this one in depth-first order.
+func (o *Object) Descendants() []*Object {
+	descendants := []*Object{}
+	for _, child := range o.children {
+		child.Walk(func(obj *Object) bool {
+			descendants = append(descendants, obj)
+			return true
+		})
+	}
+	return descendants
 }
 
 func (o *Object) TranslationMatrix() mgl32.Mat4 {
-	return o.translation
+	return mgl32.Translate3D(o.localPosition.Elem())
 }
 
 func (o *Object) RotationMatrix() mgl32.Mat4 {
-	return o.rotation
+	return o.localRotation.Mat4()
 }
 
 func (o *Object) ScaleMatrix() mgl32.Mat4 {
-	return o.scale
+	return mgl32.Scale3D(o.localScale.Elem())
 }
 
 func (o *Object) ModelMatrix() mgl32.Mat4 {
@@ -59,36 +218,274 @@ func (o *Object) ModelMatrix() mgl32.Mat4 {
 }
 
 func (o *Object) Translate(v mgl32.Vec3) {
-	o.translation = mgl32.Translate3D(v.Elem()).Mul4(o.translation)
+	o.localPosition = o.localPosition.Add(v)
+	o.markLocalDirty()
 }
 
 func (o *Object) ResetTranslation() {
-	o.translation = mgl32.Ident4()
+	o.localPosition = mgl32.Vec3{}
+	o.markLocalDirty()
 }
 
+// Rotate rotates the object around axis, which is expressed in the
+// parent's space.
 func (o *Object) Rotate(angle float32, axis mgl32.Vec3) {
-	o.rotation = mgl32.HomogRotate3D(angle, axis).Mul4(o.rotation)
+	o.localRotation = mgl32.QuatRotate(angle, axis.Normalize()).Mul(o.localRotation).Normalize()
+	o.markLocalDirty()
 }
 
 func (o *Object) ResetRotation() {
-	o.rotation = mgl32.Ident4()
+	o.localRotation = mgl32.QuatIdent()
+	o.markLocalDirty()
 }
 
 func (o *Object) Scale(v mgl32.Vec3) {
-	o.scale = mgl32.Scale3D(v.Elem()).Mul4(o.scale)
+	o.localScale = mgl32.Vec3{o.localScale[0] * v[0], o.localScale[1] * v[1], o.localScale[2] * v[2]}
+	o.markLocalDirty()
 }
 
 func (o *Object) ResetScale() {
-	o.scale = mgl32.Ident4()
+	o.localScale = mgl32.Vec3{1, 1, 1}
+	o.markLocalDirty()
+}
+
+func (o *Object) LocalPosition() mgl32.Vec3 {
+	return o.localPosition
+}
+
+func (o *Object) SetLocalPosition(position mgl32.Vec3) {
+	o.localPosition = position
+	o.markLocalDirty()
+}
+
+// SetPosition moves the object to position in world space.
+func (o *Object) SetPosition(position mgl32.Vec3) {
+	if o.parent != nil {
+		o.parent.updateTransform()
+		position = o.parent.model.Inv().Mul4x1(position.Vec4(1)).Vec3()
+	}
+	o.SetLocalPosition(position)
+}
+
+func (o *Object) LocalRotation() mgl32.Quat {
+	return o.localRotation
+}
+
+func (o *Object) SetLocalRotation(rotation mgl32.Quat) {
+	o.localRotation = rotation.Normalize()
+	o.markLocalDirty()
+}
+
+// SetRotation sets the rotation of the object in world space.
+func (o *Object) SetRotation(rotation mgl32.Quat) {
+	if o.parent != nil {
+		o.parent.updateTransform()
+		rotation = o.parent.rotation.Inverse().Mul(rotation)
+	}
+	o.SetLocalRotation(rotation)
+}
+
+// LocalEulerAngles returns the local rotation as angles in radians around
+// x, y and z, which are applied in the order z, x, y.
+func (o *Object) LocalEulerAngles() mgl32.Vec3 {
+	return quatToEulerAngles(o.localRotation)
 }
 
+func (o *Object) SetLocalEulerAngles(angles mgl32.Vec3) {
+	o.SetLocalRotation(eulerAnglesToQuat(angles))
+}
+
+func (o *Object) EulerAngles() mgl32.Vec3 {
+	return quatToEulerAngles(o.rotation)
+}
+
+func (o *Object) SetEulerAngles(angles mgl32.Vec3) {
+	o.SetRotation(eulerAnglesToQuat(angles))
+}
+
+// LookAt rotates the object so that its forward points at target in world
+// space and its up is as close to up as possible. If up is parallel to the
+// direction of target, the world axis furthest from it is used instead.
+func (o *Object) LookAt(target, up mgl32.Vec3) {
+	if o.parent != nil {
+		o.parent.updateTransform()
+	}
+	o.updateTransform()
+	forward := target.Sub(o.position)
+	if forward.Len() == 0 {
+		return
+	}
+	forward = forward.Normalize()
+	right := forward.Cross(up)
+	if right.Len() < 1e-6 {
+		up = mgl32.Vec3{1, 0, 0}
+		for _, axis := range []mgl32.Vec3{{0, 1, 0}, {0, 0, 1}} {
+			if mgl32.Abs(axis.Dot(forward)) < mgl32.Abs(up.Dot(forward)) {
+				up = axis
+			}
+		}
+		right = forward.Cross(up)
+	}
+	right = right.Normalize()
+	up = right.Cross(forward)
+	rotation := mgl32.Mat4FromCols(right.Vec4(0), up.Vec4(0), forward.Mul(-1).Vec4(0), mgl32.Vec4{0, 0, 0, 1})
+	o.SetRotation(mgl32.Mat4ToQuat(rotation))
+}
+
+// SlerpLocalRotation interpolates the local rotation towards to by amount
+// in [0, 1].
+func (o *Object) SlerpLocalRotation(to mgl32.Quat, amount float32) {
+	o.SetLocalRotation(slerp(o.localRotation, to, amount))
+}
+
+// SlerpRotation interpolates the world rotation towards to by amount
+// in [0, 1].
+func (o *Object) SlerpRotation(to mgl32.Quat, amount float32) {
+	if o.parent != nil {
+		o.parent.updateTransform()
+	}
+	o.updateTransform()
+	o.SetRotation(slerp(o.rotation, to, amount))
+}
+
+func (o *Object) LocalScale() mgl32.Vec3 {
+	return o.localScale
+}
+
+func (o *Object) SetLocalScale(scale mgl32.Vec3) {
+	o.localScale = scale
+	o.markLocalDirty()
+}
+
+// setLocalMatrix decomposes m into the local position, rotation and scale.
+// Shearing cannot be represented and is lost.
+func (o *Object) setLocalMatrix(m mgl32.Mat4) {
+	x, y, z := m.Col(0).Vec3(), m.Col(1).Vec3(), m.Col(2).Vec3()
+	scale := mgl32.Vec3{x.Len(), y.Len(), z.Len()}
+	if x.Cross(y).Dot(z) < 0 {
+		scale[0] = -scale[0]
+	}
+	divisor := scale
+	for i, s := range divisor {
+		if s == 0 {
+			divisor[i] = 1
+		}
+	}
+	rotation := mgl32.Mat4FromCols(
+		x.Mul(1/divisor[0]).Vec4(0),
+		y.Mul(1/divisor[1]).Vec4(0),
+		z.Mul(1/divisor[2]).Vec4(0),
+		mgl32.Vec4{0, 0, 0, 1},
+	)
+	o.localPosition = m.Col(3).Vec3()
+	o.localRotation = mgl32.Mat4ToQuat(rotation).Normalize()
+	o.localScale = scale
+	o.markLocalDirty()
+}
+
+func (o *Object) LocalMatrix() mgl32.Mat4 {
+	if o.localDirty {
+		o.local = o.TranslationMatrix().Mul4(o.RotationMatrix()).Mul4(o.ScaleMatrix())
+		o.localDirty = false
+	}
+	return o.local
+}
+
+func (o *Object) markLocalDirty() {
+	o.localDirty = true
+	o.markWorldDirty()
+}
+
+func (o *Object) markWorldDirty() {
+	// descendants of a dirty object are dirty already
+	if o.worldDirty {
+		return
+	}
+	o.worldDirty = true
+	for _, child := range o.children {
+		child.markWorldDirty()
+	}
+}
+
+// updateTransform rebuilds model and the transform info if needed,
+// updating dirty ancestors first.
+func (o *Object) updateTransform() {
+	if !o.worldDirty {
+		return
+	}
+	model := o.LocalMatrix()
+	rotation := o.localRotation
+	if o.parent != nil {
+		o.parent.updateTransform()
+		model = o.parent.model.Mul4(model)
+		rotation = o.parent.rotation.Mul(rotation).Normalize()
+	}
+	o.model = model
+	o.rotation = rotation
+	o.position = model.Mul4x1(mgl32.Vec4{0, 0, 0, 1}).Vec3()
+	o.up = model.Mul4x1(mgl32.Vec4{0, 1, 0, 0}).Vec3()
+	o.right = model.Mul4x1(mgl32.Vec4{1, 0, 0, 0}).Vec3()
+	o.forward = o.up.Cross(o.right)
+	o.worldDirty = false
+}
+
+// SetBehavior replaces all behaviors of the object with behavior.
 func (o *Object) SetBehavior(behavior Behavior) {
-	o.behavior = behavior
+	o.behaviors = nil
+	o.AddBehavior(behavior)
+}
+
+func (o *Object) AddBehavior(behavior Behavior) {
+	o.behaviors = append(o.behaviors, &behaviorSlot{behavior: behavior})
+}
+
+func (o *Object) RemoveBehavior(behavior Behavior) {
+	for i, slot := range o.behaviors {
+		if slot.behavior == behavior {
+			o.behaviors = append(o.behaviors[:i], o.behaviors[i+1:]...)
+			return
+		}
+	}
+}
+
+func (o *Object) Behaviors() []Behavior {
+	behaviors := make([]Behavior, len(o.behaviors))
+	for i, slot := range o.behaviors {
+		behaviors[i] = slot.behavior
+	}
+	return behaviors
 }
 
 func (o *Object) AttachComponent(component Component) {
 	component.setObject(o)
-	o.components[component.Type()] = component
+	o.components[component.Type()] = append(o.components[component.Type()], component)
+	o.attached = append(o.attached, component)
+}
+
+func (o *Object) DetachComponent(component Component) {
+	compos := o.components[component.Type()]
+	for i, c := range compos {
+		if c == component {
+			compos = append(compos[:i], compos[i+1:]...)
+			break
+		}
+	}
+	if len(compos) == 0 {
+		delete(o.components, component.Type())
+	} else {
+		o.components[component.Type()] = compos
+	}
+	for i, c := range o.attached {
+		if c == component {
+			o.attached = append(o.attached[:i], o.attached[i+1:]...)
+			break
+		}
+	}
+	component.setObject(nil)
+}
+
+func (o *Object) ComponentsOfType(componentType int) []Component {
+	return o.components[componentType]
 }
 
 func (o *Object) Components() ComponentMap {
@@ -97,12 +494,17 @@ func (o *Object) Components() ComponentMap {
 
 type TransformInfo struct {
 	position, up, right, forward mgl32.Vec3
+	rotation                     mgl32.Quat
 }
 
 func (t *TransformInfo) Position() mgl32.Vec3 {
 	return t.position
 }
 
+func (t *TransformInfo) Rotation() mgl32.Quat {
+	return t.rotation
+}
+
 func (t *TransformInfo) Up() mgl32.Vec3 {
 	return t.up
 }
@@ -115,14 +517,32 @@ func (t *TransformInfo) Forward() mgl32.Vec3 {
 	return t.forward
 }
 
-type BehaviorContext struct {
-	Context   *Context
-	Object    *Object
-	DeltaTime float64
-	Time      float64
+func eulerAnglesToQuat(angles mgl32.Vec3) mgl32.Quat {
+	return mgl32.QuatRotate(angles[1], mgl32.Vec3{0, 1, 0}).
+		Mul(mgl32.QuatRotate(angles[0], mgl32.Vec3{1, 0, 0})).
+		Mul(mgl32.QuatRotate(angles[2], mgl32.Vec3{0, 0, 1}))
+}
+
+func quatToEulerAngles(q mgl32.Quat) mgl32.Vec3 {
+	m := q.Normalize().Mat4().Mat3()
+	sx := -m.At(1, 2)
+	if sx >= 0.9999 || sx <= -0.9999 {
+		// gimbal lock, fold roll into yaw
+		x := float32(math.Copysign(math.Pi/2, float64(sx)))
+		y := float32(math.Atan2(float64(-m.At(2, 0)), float64(m.At(0, 0))))
+		return mgl32.Vec3{x, y, 0}
+	}
+	return mgl32.Vec3{
+		float32(math.Asin(float64(sx))),
+		float32(math.Atan2(float64(m.At(0, 2)), float64(m.At(2, 2)))),
+		float32(math.Atan2(float64(m.At(1, 0)), float64(m.At(1, 1)))),
+	}
 }
 
-type Behavior interface {
-	Start(bctx *BehaviorContext)
-	Update(bctx *BehaviorContext)
+// slerp takes the shortest path between from and to.
+func slerp(from, to mgl32.Quat, amount float32) mgl32.Quat {
+	if from.Dot(to) < 0 {
+		to = to.Scale(-1)
+	}
+	return mgl32.QuatSlerp(from, to, amount).Normalize()
 }
@@ -0,0 +1,267 @@
+package wengine
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"math"
+	"testing"
+)
+
+func vec3Near(a, b mgl32.Vec3) bool {
+	return a.Sub(b).Len() < 1e-4
+}
+
+// newChain makes a root with a child and a grandchild, each one unit above
+// its parent.
+func newChain() (root, child, grandchild *Object) {
+	root, child, grandchild = NewObject(), NewObject(), NewObject()
+	child.Translate(mgl32.Vec3{0, 1, 0})
+	grandchild.Translate(mgl32.Vec3{0, 1, 0})
+	child.SetParent(root, false)
+	grandchild.SetParent(child, false)
+	return
+}
+
+func TestTransformDirtyPropagation(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(root, child, grandchild *Object)
+		want   mgl32.Vec3
+	}{
+		{"unchanged", func(root, child, grandchild *Object) {}, mgl32.Vec3{0, 2, 0}},
+		{"root moved", func(root, child, grandchild *Object) {
+			root.Translate(mgl32.Vec3{5, 0, 0})
+		}, mgl32.Vec3{5, 2, 0}},
+		{"child moved", func(root, child, grandchild *Object) {
+			child.SetLocalPosition(mgl32.Vec3{0, 3, 0})
+		}, mgl32.Vec3{0, 4, 0}},
+		{"root rotated", func(root, child, grandchild *Object) {
+			root.Rotate(mgl32.DegToRad(90), mgl32.Vec3{0, 0, 1})
+		}, mgl32.Vec3{-2, 0, 0}},
+		{"root scaled", func(root, child, grandchild *Object) {
+			root.Scale(mgl32.Vec3{2, 2, 2})
+		}, mgl32.Vec3{0, 4, 0}},
+		{"root moved twice", func(root, child, grandchild *Object) {
+			root.Translate(mgl32.Vec3{1, 0, 0})
+			grandchild.updateTransform()
+			root.Translate(mgl32.Vec3{1, 0, 0})
+		}, mgl32.Vec3{2, 2, 0}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root, child, grandchild := newChain()
+			grandchild.updateTransform()
+			test.change(root, child, grandchild)
+			if !grandchild.worldDirty && (root.worldDirty || child.worldDirty) {
+				t.Fatal("descendant of a dirty object is clean")
+			}
+			grandchild.updateTransform()
+			if got := grandchild.Position(); !vec3Near(got, test.want) {
+				t.Errorf("position = %v, want %v", got, test.want)
+			}
+			if grandchild.worldDirty || child.worldDirty || root.worldDirty {
+				t.Error("still dirty after update")
+			}
+		})
+	}
+}
+
+func TestLocalMatrixCached(t *testing.T) {
+	o := NewObject()
+	o.Translate(mgl32.Vec3{1, 2, 3})
+	first := o.LocalMatrix()
+	if o.localDirty {
+		t.Fatal("dirty after LocalMatrix")
+	}
+	o.local = mgl32.Ident4()
+	if o.LocalMatrix() != mgl32.Ident4() {
+		t.Fatal("clean local matrix rebuilt")
+	}
+	o.SetLocalScale(mgl32.Vec3{1, 1, 1})
+	if got := o.LocalMatrix(); got != first {
+		t.Errorf("local matrix = %v, want %v", got, first)
+	}
+}
+
+func TestSetParent(t *testing.T) {
+	tests := []struct {
+		name    string
+		parent  func(root, child, grandchild *Object) (object, parent *Object)
+		wantErr bool
+	}{
+		{"to itself", func(root, child, grandchild *Object) (*Object, *Object) { return root, root }, true},
+		{"to its child", func(root, child, grandchild *Object) (*Object, *Object) { return root, child }, true},
+		{"to its grandchild", func(root, child, grandchild *Object) (*Object, *Object) { return root, grandchild }, true},
+		{"to its parent", func(root, child, grandchild *Object) (*Object, *Object) { return grandchild, child }, false},
+		{"to its grandparent", func(root, child, grandchild *Object) (*Object, *Object) { return grandchild, root }, false},
+		{"to the root", func(root, child, grandchild *Object) (*Object, *Object) { return child, nil }, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			root, child, grandchild := newChain()
+			object, parent := test.parent(root, child, grandchild)
+			oldParent := object.Parent()
+			err := object.SetParent(parent, true)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err = %v, want error %v", err, test.wantErr)
+			}
+			if err != nil {
+				if object.Parent() != oldParent {
+					t.Error("parent changed on error")
+				}
+				return
+			}
+			if object.Parent() != parent {
+				t.Error("parent not set")
+			}
+			if oldParent != nil && oldParent != parent {
+				for _, c := range oldParent.Children() {
+					if c == object {
+						t.Error("still a child of the old parent")
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSetParentKeepWorldTransform(t *testing.T) {
+	root, child, grandchild := newChain()
+	root.Rotate(mgl32.DegToRad(45), mgl32.Vec3{0, 1, 0})
+	root.Scale(mgl32.Vec3{2, 2, 2})
+	grandchild.updateTransform()
+	want := grandchild.Position()
+
+	other := NewObject()
+	other.Translate(mgl32.Vec3{3, 0, -1})
+	if err := grandchild.SetParent(other, true); err != nil {
+		t.Fatal(err)
+	}
+	grandchild.updateTransform()
+	if got := grandchild.Position(); !vec3Near(got, want) {
+		t.Errorf("position = %v, want %v", got, want)
+	}
+	if len(child.Children()) != 0 {
+		t.Error("grandchild not removed from child")
+	}
+
+	grandchild.Detach()
+	grandchild.updateTransform()
+	if got := grandchild.Position(); !vec3Near(got, want) || grandchild.Parent() != nil {
+		t.Errorf("detached position = %v, want %v", got, want)
+	}
+}
+
+// quatNear compares rotations, q and -q being the same one.
+func quatNear(a, b mgl32.Quat) bool {
+	return mgl32.Abs(a.Dot(b)) > 1-1e-5
+}
+
+func TestEulerAnglesRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		angles mgl32.Vec3
+		want   mgl32.Vec3
+	}{
+		{"zero", mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 0, 0}},
+		{"yaw", mgl32.Vec3{0, 1, 0}, mgl32.Vec3{0, 1, 0}},
+		{"pitch", mgl32.Vec3{-0.5, 0, 0}, mgl32.Vec3{-0.5, 0, 0}},
+		{"roll", mgl32.Vec3{0, 0, 2}, mgl32.Vec3{0, 0, 2}},
+		{"all", mgl32.Vec3{0.3, -2, 1.2}, mgl32.Vec3{0.3, -2, 1.2}},
+		{"gimbal lock folds roll into yaw", mgl32.Vec3{math.Pi / 2, 0.5, 0.25}, mgl32.Vec3{math.Pi / 2, 0.25, 0}},
+		{"gimbal lock down", mgl32.Vec3{-math.Pi / 2, 0.5, 0}, mgl32.Vec3{-math.Pi / 2, 0.5, 0}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			o := NewObject()
+			o.SetLocalEulerAngles(test.angles)
+			got := o.LocalEulerAngles()
+			if !vec3Near(got, test.want) {
+				t.Errorf("LocalEulerAngles() = %v, want %v", got, test.want)
+			}
+			// whatever the angles, they give back the same rotation
+			if rotation := eulerAnglesToQuat(got); !quatNear(rotation, o.LocalRotation()) {
+				t.Errorf("rotation of %v = %v, want %v", got, rotation, o.LocalRotation())
+			}
+		})
+	}
+}
+
+func TestLookAt(t *testing.T) {
+	tests := []struct {
+		name   string
+		target mgl32.Vec3
+		up     mgl32.Vec3
+		wantUp mgl32.Vec3
+	}{
+		{"ahead", mgl32.Vec3{0, 0, -5}, mgl32.Vec3{0, 1, 0}, mgl32.Vec3{0, 1, 0}},
+		{"right", mgl32.Vec3{3, 0, 0}, mgl32.Vec3{0, 1, 0}, mgl32.Vec3{0, 1, 0}},
+		{"behind rolled", mgl32.Vec3{0, 0, 2}, mgl32.Vec3{1, 0, 0}, mgl32.Vec3{1, 0, 0}},
+		{"up not perpendicular", mgl32.Vec3{0, 0, -1}, mgl32.Vec3{0, 1, 1}, mgl32.Vec3{0, 1, 0}},
+		{"straight up", mgl32.Vec3{0, 4, 0}, mgl32.Vec3{0, 1, 0}, mgl32.Vec3{1, 0, 0}},
+		{"straight down", mgl32.Vec3{0, -4, 0}, mgl32.Vec3{0, 1, 0}, mgl32.Vec3{1, 0, 0}},
+		{"along x with x up", mgl32.Vec3{2, 0, 0}, mgl32.Vec3{1, 0, 0}, mgl32.Vec3{0, 1, 0}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parent := NewObject()
+			parent.Translate(mgl32.Vec3{0, 0, 1})
+			parent.Rotate(1, mgl32.Vec3{0, 1, 0})
+			o := NewObject()
+			o.SetParent(parent, false)
+			o.SetPosition(mgl32.Vec3{0, 0, 0})
+
+			o.LookAt(test.target, test.up)
+			o.updateTransform()
+			if want := test.target.Normalize(); !vec3Near(o.Forward(), want) {
+				t.Errorf("forward = %v, want %v", o.Forward(), want)
+			}
+			if !vec3Near(o.Up(), test.wantUp) {
+				t.Errorf("up = %v, want %v", o.Up(), test.wantUp)
+			}
+		})
+	}
+
+	o := NewObject()
+	o.SetLocalEulerAngles(mgl32.Vec3{0, 1, 0})
+	o.LookAt(mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 1, 0})
+	if !vec3Near(o.LocalEulerAngles(), mgl32.Vec3{0, 1, 0}) {
+		t.Errorf("looking at its own position rotated it to %v", o.LocalEulerAngles())
+	}
+}
+
+func TestSlerp(t *testing.T) {
+	from := mgl32.QuatRotate(0.2, mgl32.Vec3{0, 1, 0})
+	to := mgl32.QuatRotate(1.8, mgl32.Vec3{0, 1, 0})
+	tests := []struct {
+		name   string
+		to     mgl32.Quat
+		amount float32
+		want   mgl32.Quat
+	}{
+		{"start", to, 0, from},
+		{"end", to, 1, to},
+		{"half", to, 0.5, mgl32.QuatRotate(1, mgl32.Vec3{0, 1, 0})},
+		{"negated end", to.Scale(-1), 1, to},
+		{"negated half", to.Scale(-1), 0.5, mgl32.QuatRotate(1, mgl32.Vec3{0, 1, 0})},
+		// 0.2 to 6 radians is shorter the other way round, through 0
+		{"shortest path", mgl32.QuatRotate(6, mgl32.Vec3{0, 1, 0}), 0.5, mgl32.QuatRotate(0.2-(0.2+2*math.Pi-6)/2, mgl32.Vec3{0, 1, 0})},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := slerp(from, test.to, test.amount)
+			if !quatNear(got, test.want) {
+				t.Errorf("slerp(%v) = %v, want %v", test.amount, got, test.want)
+			}
+			if math.Abs(float64(got.Len()-1)) > 1e-5 {
+				t.Errorf("length %v, want 1", got.Len())
+			}
+
+			o := NewObject()
+			o.SetLocalRotation(from)
+			o.SlerpLocalRotation(test.to, test.amount)
+			if !quatNear(o.LocalRotation(), test.want) {
+				t.Errorf("SlerpLocalRotation(%v) = %v, want %v", test.amount, o.LocalRotation(), test.want)
+			}
+		})
+	}
+}
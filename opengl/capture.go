@@ -0,0 +1,149 @@
+package opengl
+
+import (
+	"errors"
+	"github.com/go-gl/gl/v3.2-core/gl"
+	. "github.com/wxdao/wengine"
+	"image"
+)
+
+// captureRegion is the rectangle of a framebuffer to read back, in GL
+// coordinates.
+type captureRegion struct {
+	fbo                 uint32
+	x, y, width, height int
+}
+
+// pendingCapture is an asynchronous capture whose pixels are on their way
+// into a pixel buffer object.
+type pendingCapture struct {
+	pbo           uint32
+	fence         uintptr
+	width, height int
+	done          func(img *image.RGBA, err error)
+}
+
+func (r *renderer) captureRegion(camera *CameraComponent) (captureRegion, error) {
+	screen := r.screenTarget()
+	region := captureRegion{fbo: screen.fbo, width: screen.width, height: screen.height}
+	if camera == nil {
+		return region, nil
+	}
+	if camera.TargetTexture != "" {
+		texture, err := r.renderTexture(camera.TargetTexture)
+		if err != nil {
+			return region, err
+		}
+		region.fbo, region.width, region.height = texture.fbo, texture.width, texture.height
+	}
+	scrWidth, scrHeight := region.width, region.height
+	region.x = int(float32(scrWidth) * camera.ViewportX)
+	region.y = int(float32(scrHeight) * camera.ViewportY)
+	region.width = int(float32(scrWidth) * camera.ViewportW)
+	region.height = int(float32(scrHeight) * camera.ViewportH)
+	return region, nil
+}
+
+// readPixels reads region into pixels, or into the bound pixel pack buffer
+// if pixels is nil.
+func (r *renderer) readPixels(region captureRegion, pixels []uint8) {
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, region.fbo)
+	if region.fbo == 0 {
+		gl.ReadBuffer(gl.BACK)
+	} else {
+		gl.ReadBuffer(gl.COLOR_ATTACHMENT0)
+	}
+	gl.PixelStorei(gl.PACK_ALIGNMENT, 1)
+	if pixels != nil {
+		gl.ReadPixels(int32(region.x), int32(region.y), int32(region.width), int32(region.height), gl.RGBA, gl.UNSIGNED_BYTE, gl.Ptr(pixels))
+	} else {
+		gl.ReadPixels(int32(region.x), int32(region.y), int32(region.width), int32(region.height), gl.RGBA, gl.UNSIGNED_BYTE, gl.PtrOffset(0))
+	}
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, 0)
+}
+
+func (r *renderer) Capture(camera *CameraComponent) (*image.RGBA, error) {
+	region, err := r.captureRegion(camera)
+	if err != nil {
+		return nil, err
+	}
+	if region.width <= 0 || region.height <= 0 {
+		return nil, errors.New("nothing to capture")
+	}
+	pixels := make([]uint8, region.width*region.height*4)
+	r.readPixels(region, pixels)
+	return flipPixels(pixels, region.width, region.height), nil
+}
+
+// CaptureAsync reads back into a pixel buffer object, which is copied out
+// by the first Render after the GPU is done with it.
+func (r *renderer) CaptureAsync(camera *CameraComponent, done func(img *image.RGBA, err error)) error {
+	region, err := r.captureRegion(camera)
+	if err != nil {
+		return err
+	}
+	if region.width <= 0 || region.height <= 0 {
+		return errors.New("nothing to capture")
+	}
+	capture := &pendingCapture{width: region.width, height: region.height, done: done}
+	gl.GenBuffers(1, &capture.pbo)
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, capture.pbo)
+	gl.BufferData(gl.PIXEL_PACK_BUFFER, region.width*region.height*4, nil, gl.STREAM_READ)
+	r.readPixels(region, nil)
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, 0)
+	capture.fence = gl.FenceSync(gl.SYNC_GPU_COMMANDS_COMPLETE, 0)
+	r.pendingCaptures = append(r.pendingCaptures, capture)
+	return nil
+}
+
+func (r *renderer) FinishCaptures() error {
+	r.pollCaptures(true)
+	return nil
+}
+
+// pollCaptures delivers the asynchronous captures the GPU is done with, in
+// the order they were made. With wait set, it waits for all of them; those
+// that fail are delivered with an error.
+func (r *renderer) pollCaptures(wait bool) {
+	for len(r.pendingCaptures) > 0 {
+		capture := r.pendingCaptures[0]
+		var flags uint32
+		var timeout uint64
+		if wait {
+			flags, timeout = gl.SYNC_FLUSH_COMMANDS_BIT, 1000000000
+		}
+		status := gl.ClientWaitSync(capture.fence, flags, timeout)
+		if status != gl.ALREADY_SIGNALED && status != gl.CONDITION_SATISFIED {
+			if status != gl.WAIT_FAILED && !wait {
+				return
+			}
+			r.pendingCaptures = r.pendingCaptures[1:]
+			capture.delete()
+			capture.done(nil, errors.New("capture failed"))
+			continue
+		}
+		r.pendingCaptures = r.pendingCaptures[1:]
+
+		pixels := make([]uint8, capture.width*capture.height*4)
+		gl.BindBuffer(gl.PIXEL_PACK_BUFFER, capture.pbo)
+		gl.GetBufferSubData(gl.PIXEL_PACK_BUFFER, 0, len(pixels), gl.Ptr(pixels))
+		gl.BindBuffer(gl.PIXEL_PACK_BUFFER, 0)
+		capture.delete()
+		capture.done(flipPixels(pixels, capture.width, capture.height), nil)
+	}
+}
+
+func (c *pendingCapture) delete() {
+	gl.DeleteBuffers(1, &c.pbo)
+	gl.DeleteSync(c.fence)
+}
+
+// flipPixels turns rows read bottom up by GL into an image.
+func flipPixels(pixels []uint8, width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	stride := width * 4
+	for y := 0; y < height; y++ {
+		copy(img.Pix[y*img.Stride:y*img.Stride+stride], pixels[(height-1-y)*stride:(height-y)*stride])
+	}
+	return img
+}
@@ -12,11 +12,11 @@ import (
 type deferredShading struct {
 	renderer *renderer
 
-	gBuffer   uint32
-	gPosition uint32
-	gNormal   uint32
-	gDiffuse  uint32
-	gDepth    uint32
+	// gBuffers are keyed by viewport size, g is the one of the current
+	// camera
+	gBuffers map[[2]int]*gBuffer
+	g        *gBuffer
+	target   renderTarget
 
 	sDirBuffer   uint32
 	sDirMap      uint32
@@ -27,13 +27,15 @@ type deferredShading struct {
 	sSpotMap     uint32
 	sSpotDepth   uint32
 
+	// meters measure the luminance cameras see, for auto exposure
+	meters map[*CameraComponent]*exposureMeter
+
 	quad uint32
 }
 
 func (r *deferredShading) init() error {
-	if err := r.initGBuffer(); err != nil {
-		return err
-	}
+	r.gBuffers = map[[2]int]*gBuffer{}
+	r.meters = map[*CameraComponent]*exposureMeter{}
 
 	if err := r.initSBuffer(); err != nil {
 		return err
@@ -46,48 +48,129 @@ func (r *deferredShading) init() error {
 	return nil
 }
 
-func (r *deferredShading) initGBuffer() error {
-	scrWidth, scrHeight := r.renderer.context.ScreenSize()
+// gBuffer is the geometry buffer for camera viewports of one size, along
+// with the floating-point buffer lights accumulate into before tone mapping.
+type gBuffer struct {
+	width, height int
 
-	gl.GenFramebuffers(1, &r.gBuffer)
-	gl.BindFramebuffer(gl.FRAMEBUFFER, r.gBuffer)
+	fbo      uint32
+	position uint32
+	normal   uint32
+	diffuse  uint32
+	depth    uint32
 
-	gl.GenTextures(1, &r.gPosition)
-	gl.BindTexture(gl.TEXTURE_2D, r.gPosition)
-	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGB32F, int32(scrWidth), int32(scrHeight), 0, gl.RGB, gl.FLOAT, nil)
+	hdrFBO uint32
+	hdr    uint32
+}
+
+// useGBuffer makes the gBuffer of the viewport size current, creating it on
+// first use.
+func (r *deferredShading) useGBuffer(width, height int) error {
+	if g, exists := r.gBuffers[[2]int{width, height}]; exists {
+		r.g = g
+		return nil
+	}
+	g, err := newGBuffer(width, height)
+	if err != nil {
+		return err
+	}
+	r.gBuffers[[2]int{width, height}] = g
+	r.g = g
+	return nil
+}
+
+func newGBuffer(width, height int) (*gBuffer, error) {
+	g := &gBuffer{width: width, height: height}
+
+	gl.GenFramebuffers(1, &g.fbo)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, g.fbo)
+
+	gl.GenTextures(1, &g.position)
+	gl.BindTexture(gl.TEXTURE_2D, g.position)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGB32F, int32(width), int32(height), 0, gl.RGB, gl.FLOAT, nil)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
-	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, r.gPosition, 0)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, g.position, 0)
 
-	gl.GenTextures(1, &r.gNormal)
-	gl.BindTexture(gl.TEXTURE_2D, r.gNormal)
-	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGB32F, int32(scrWidth), int32(scrHeight), 0, gl.RGB, gl.FLOAT, nil)
+	gl.GenTextures(1, &g.normal)
+	gl.BindTexture(gl.TEXTURE_2D, g.normal)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGB32F, int32(width), int32(height), 0, gl.RGB, gl.FLOAT, nil)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
-	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT1, gl.TEXTURE_2D, r.gNormal, 0)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT1, gl.TEXTURE_2D, g.normal, 0)
 
-	gl.GenTextures(1, &r.gDiffuse)
-	gl.BindTexture(gl.TEXTURE_2D, r.gDiffuse)
-	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA, int32(scrWidth), int32(scrHeight), 0, gl.RGBA, gl.UNSIGNED_BYTE, nil)
+	gl.GenTextures(1, &g.diffuse)
+	gl.BindTexture(gl.TEXTURE_2D, g.diffuse)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA16F, int32(width), int32(height), 0, gl.RGBA, gl.FLOAT, nil)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.NEAREST)
 	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.NEAREST)
-	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT2, gl.TEXTURE_2D, r.gDiffuse, 0)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT2, gl.TEXTURE_2D, g.diffuse, 0)
 
 	gl.DrawBuffers(3, &[]uint32{gl.COLOR_ATTACHMENT0, gl.COLOR_ATTACHMENT1, gl.COLOR_ATTACHMENT2}[0])
 
-	gl.GenRenderbuffers(1, &r.gDepth)
-	gl.BindRenderbuffer(gl.RENDERBUFFER, r.gDepth)
-	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.DEPTH_COMPONENT, int32(scrWidth), int32(scrHeight))
-	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.RENDERBUFFER, r.gDepth)
+	gl.GenRenderbuffers(1, &g.depth)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, g.depth)
+	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.DEPTH_COMPONENT, int32(width), int32(height))
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.RENDERBUFFER, g.depth)
 
 	if gl.CheckFramebufferStatus(gl.FRAMEBUFFER) != gl.FRAMEBUFFER_COMPLETE {
-		return errors.New("framebuffer failed")
+		g.abandon()
+		return nil, errors.New("framebuffer failed")
+	}
+
+	gl.GenFramebuffers(1, &g.hdrFBO)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, g.hdrFBO)
+
+	gl.GenTextures(1, &g.hdr)
+	gl.BindTexture(gl.TEXTURE_2D, g.hdr)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, gl.RGBA16F, int32(width), int32(height), 0, gl.RGBA, gl.FLOAT, nil)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, g.hdr, 0)
+
+	if gl.CheckFramebufferStatus(gl.FRAMEBUFFER) != gl.FRAMEBUFFER_COMPLETE {
+		g.abandon()
+		return nil, errors.New("framebuffer failed")
 	}
 
 	gl.BindRenderbuffer(gl.RENDERBUFFER, 0)
 	gl.BindTexture(gl.TEXTURE_2D, 0)
 	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
 
+	return g, nil
+}
+
+// abandon unbinds and deletes a gBuffer newGBuffer failed to complete. The
+// objects not created yet are 0, which GL ignores.
+func (g *gBuffer) abandon() {
+	gl.BindRenderbuffer(gl.RENDERBUFFER, 0)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	g.delete()
+}
+
+func (g *gBuffer) delete() {
+	gl.DeleteFramebuffers(2, &[]uint32{g.fbo, g.hdrFBO}[0])
+	gl.DeleteTextures(4, &[]uint32{g.position, g.normal, g.diffuse, g.hdr}[0])
+	gl.DeleteRenderbuffers(1, &g.depth)
+}
+
+// resize drops the gBuffers of sizes no target has anymore, the screen
+// being width by height now. Those of smaller viewports are dropped as well,
+// and made again on use.
+func (r *deferredShading) resize(width, height int) error {
+	used := map[[2]int]bool{{width, height}: true}
+	for _, texture := range r.renderer.renderTextures {
+		used[[2]int{texture.width, texture.height}] = true
+	}
+	for size, g := range r.gBuffers {
+		if used[size] {
+			continue
+		}
+		g.delete()
+		delete(r.gBuffers, size)
+	}
+	r.g = nil
 	return nil
 }
 
@@ -221,7 +304,27 @@ func (r *deferredShading) initQuad() error {
 	return nil
 }
 
-func (r *deferredShading) render(targetFBO uint32, lights []*LightComponent, meshes []*MeshComponent, sprites []*SpriteComponent, scene *Scene, camera *CameraComponent) error {
+// viewportSize is the size of the viewport of camera in the current target,
+// which the passes up to tone mapping draw at.
+func (r *deferredShading) viewportSize(camera *CameraComponent) (width, height int) {
+	width = int(float32(r.target.width) * camera.ViewportW)
+	height = int(float32(r.target.height) * camera.ViewportH)
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	return
+}
+
+func (r *deferredShading) render(target renderTarget, lights []*LightComponent, meshes []*MeshComponent, sprites []*SpriteComponent, scene *Scene, camera *CameraComponent) error {
+	r.target = target
+	targetFBO := target.fbo
+	if err := r.useGBuffer(r.viewportSize(camera)); err != nil {
+		return err
+	}
+
 	// for meshes
 	err := r.geometryPass(lights, meshes, camera)
 	if err != nil {
@@ -233,7 +336,19 @@ func (r *deferredShading) render(targetFBO uint32, lights []*LightComponent, mes
 		return err
 	}
 
-	err = r.lightsPass(targetFBO, lights, meshes, camera)
+	err = r.lightsPass(lights, meshes, camera)
+	if err != nil {
+		return err
+	}
+
+	if camera.AutoExposure {
+		r.meter(camera).measure(r.g, camera, r.renderer.context.UnscaledDeltaTime())
+	} else if m, exists := r.meters[camera]; exists {
+		m.delete()
+		delete(r.meters, camera)
+	}
+
+	err = r.resolvePass(target, camera)
 	if err != nil {
 		return err
 	}
@@ -248,10 +363,10 @@ func (r *deferredShading) render(targetFBO uint32, lights []*LightComponent, mes
 }
 
 func (r *deferredShading) geometryPass(lights []*LightComponent, meshes []*MeshComponent, camera *CameraComponent) error {
-	scrWidth, scrHeight := r.renderer.context.ScreenSize()
-	gl.Viewport(0, 0, int32(scrWidth), int32(scrHeight))
+	scrWidth, scrHeight := r.target.width, r.target.height
+	gl.Viewport(0, 0, int32(r.g.width), int32(r.g.height))
 
-	gl.BindFramebuffer(gl.FRAMEBUFFER, r.gBuffer)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, r.g.fbo)
 	gl.ClearColor(0, 0, 0, 0)
 	gl.Clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT)
 
@@ -325,9 +440,7 @@ func (r *deferredShading) geometryPass(lights []*LightComponent, meshes []*MeshC
 }
 
 func (r *deferredShading) blendAmbient(targetFBO uint32, camera *CameraComponent) error {
-	scrWidth, scrHeight := r.renderer.context.ScreenSize()
-	gl.Viewport(int32(float32(scrWidth)*camera.ViewportX), int32(float32(scrHeight)*camera.ViewportY), int32(float32(scrWidth)*camera.ViewportW), int32(float32(scrHeight)*camera.ViewportH))
-
+	scrWidth, scrHeight := r.target.width, r.target.height
 	gl.BindFramebuffer(gl.FRAMEBUFFER, targetFBO)
 
 	gl.Enable(gl.SCISSOR_TEST)
@@ -341,12 +454,16 @@ func (r *deferredShading) blendAmbient(targetFBO uint32, camera *CameraComponent
 	}
 	gl.Disable(gl.SCISSOR_TEST)
 
-	shader := defaultShaders["deferred_ambient"]
+	// the ambient light starts off the HDR buffer the lights add up in
+	gl.Viewport(0, 0, int32(r.g.width), int32(r.g.height))
+	gl.BindFramebuffer(gl.FRAMEBUFFER, r.g.hdrFBO)
+
+	shader := r.renderer.defaultShader("deferred_ambient")
 	gl.UseProgram(shader.program)
 
 	gl.Uniform3fv(shader.getLocation("ambient"), 1, &camera.Ambient[0])
 	gl.ActiveTexture(gl.TEXTURE0)
-	gl.BindTexture(gl.TEXTURE_2D, r.gDiffuse)
+	gl.BindTexture(gl.TEXTURE_2D, r.g.diffuse)
 	gl.Uniform1i(shader.getLocation("gDiffuse"), 0)
 
 	gl.BindVertexArray(r.quad)
@@ -358,22 +475,23 @@ func (r *deferredShading) blendAmbient(targetFBO uint32, camera *CameraComponent
 	return nil
 }
 
-func (r *deferredShading) lightsPass(targetFBO uint32, lights []*LightComponent, meshes []*MeshComponent, camera *CameraComponent) error {
+// lightsPass adds the lights up in the HDR buffer.
+func (r *deferredShading) lightsPass(lights []*LightComponent, meshes []*MeshComponent, camera *CameraComponent) error {
 	for _, light := range lights {
 		var shadowMapShader, shader *glShaderProgram
 		switch light.LightSource {
 		case LIGHT_SOURCE_DIRECTIONAL:
 			switch light.ShadowType {
 			case LIGHT_SHADOW_TYPE_NONE:
-				shader = defaultShaders["deferred_dirLight_noshadow"]
+				shader = r.renderer.defaultShader("deferred_dirLight_noshadow")
 				gl.UseProgram(shader.program)
 			default:
-				shadowMapShader = defaultShaders["shadow_map_dirLight"]
+				shadowMapShader = r.renderer.defaultShader("shadow_map_dirLight")
 				lightMatrix, err := r.generateDirLightShadowMap(shadowMapShader, light, meshes, camera)
 				if err != nil {
 					return err
 				}
-				shader = defaultShaders["deferred_dirLight"]
+				shader = r.renderer.defaultShader("deferred_dirLight")
 				gl.UseProgram(shader.program)
 
 				gl.UniformMatrix4fv(shader.getLocation("lightMatrix"), 1, false, &lightMatrix[0])
@@ -411,15 +529,15 @@ func (r *deferredShading) lightsPass(targetFBO uint32, lights []*LightComponent,
 		case LIGHT_SOURCE_POINT:
 			switch light.ShadowType {
 			case LIGHT_SHADOW_TYPE_NONE:
-				shader = defaultShaders["deferred_pointLight_noshadow"]
+				shader = r.renderer.defaultShader("deferred_pointLight_noshadow")
 				gl.UseProgram(shader.program)
 			default:
-				shadowMapShader = defaultShaders["shadow_map_pointLight"]
+				shadowMapShader = r.renderer.defaultShader("shadow_map_pointLight")
 				err := r.generatePointLightShadowMap(shadowMapShader, light, meshes, camera)
 				if err != nil {
 					return err
 				}
-				shader = defaultShaders["deferred_pointLight"]
+				shader = r.renderer.defaultShader("deferred_pointLight")
 				gl.UseProgram(shader.program)
 
 				gl.ActiveTexture(gl.TEXTURE3)
@@ -455,15 +573,15 @@ func (r *deferredShading) lightsPass(targetFBO uint32, lights []*LightComponent,
 		case LIGHT_SOURCE_SPOT:
 			switch light.ShadowType {
 			case LIGHT_SHADOW_TYPE_NONE:
-				shader = defaultShaders["deferred_spotLight_noshadow"]
+				shader = r.renderer.defaultShader("deferred_spotLight_noshadow")
 				gl.UseProgram(shader.program)
 			default:
-				shadowMapShader = defaultShaders["shadow_map_spotLight"]
+				shadowMapShader = r.renderer.defaultShader("shadow_map_spotLight")
 				lightMatrix, err := r.generateSpotLightShadowMap(shadowMapShader, light, meshes, camera)
 				if err != nil {
 					return err
 				}
-				shader = defaultShaders["deferred_spotLight"]
+				shader = r.renderer.defaultShader("deferred_spotLight")
 				gl.UseProgram(shader.program)
 
 				gl.UniformMatrix4fv(shader.getLocation("lightMatrix"), 1, false, &lightMatrix[0])
@@ -522,16 +640,16 @@ func (r *deferredShading) lightsPass(targetFBO uint32, lights []*LightComponent,
 		)
 
 		gl.ActiveTexture(gl.TEXTURE0)
-		gl.BindTexture(gl.TEXTURE_2D, r.gPosition)
+		gl.BindTexture(gl.TEXTURE_2D, r.g.position)
 		gl.Uniform1i(shader.getLocation("gPosition"), 0)
 		gl.ActiveTexture(gl.TEXTURE1)
-		gl.BindTexture(gl.TEXTURE_2D, r.gNormal)
+		gl.BindTexture(gl.TEXTURE_2D, r.g.normal)
 		gl.Uniform1i(shader.getLocation("gNormal"), 1)
 		gl.ActiveTexture(gl.TEXTURE2)
-		gl.BindTexture(gl.TEXTURE_2D, r.gDiffuse)
+		gl.BindTexture(gl.TEXTURE_2D, r.g.diffuse)
 		gl.Uniform1i(shader.getLocation("gDiffuse"), 2)
 
-		gl.BindFramebuffer(gl.FRAMEBUFFER, targetFBO)
+		gl.BindFramebuffer(gl.FRAMEBUFFER, r.g.hdrFBO)
 		gl.Enable(gl.BLEND)
 		gl.BlendFunc(gl.ONE, gl.ONE)
 
@@ -549,17 +667,49 @@ func (r *deferredShading) lightsPass(targetFBO uint32, lights []*LightComponent,
 	return nil
 }
 
+// resolvePass tone maps the HDR buffer into the viewport of the camera,
+// encoding it to sRGB unless the target stays linear.
+func (r *deferredShading) resolvePass(target renderTarget, camera *CameraComponent) error {
+	scrWidth, scrHeight := r.target.width, r.target.height
+	gl.Viewport(int32(float32(scrWidth)*camera.ViewportX), int32(float32(scrHeight)*camera.ViewportY), int32(float32(scrWidth)*camera.ViewportW), int32(float32(scrHeight)*camera.ViewportH))
+
+	gl.BindFramebuffer(gl.FRAMEBUFFER, target.fbo)
+
+	shader := r.renderer.defaultShader("deferred_tonemap")
+	gl.UseProgram(shader.program)
+
+	gl.Uniform1f(shader.getLocation("exposure"), float32(math.Exp2(float64(camera.TotalExposure()))))
+	gl.Uniform1i(shader.getLocation("toneMapping"), int32(camera.ToneMapping))
+	if target.linear {
+		gl.Uniform1i(shader.getLocation("encode"), 0)
+	} else {
+		gl.Uniform1i(shader.getLocation("encode"), 1)
+	}
+	gl.ActiveTexture(gl.TEXTURE0)
+	gl.BindTexture(gl.TEXTURE_2D, r.g.hdr)
+	gl.Uniform1i(shader.getLocation("hdr"), 0)
+
+	gl.BindVertexArray(r.quad)
+	gl.DrawArrays(gl.TRIANGLES, 0, 6)
+	gl.BindVertexArray(0)
+
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	gl.UseProgram(0)
+
+	return nil
+}
+
 func (r *deferredShading) finalPass(targetFBO uint32, camera *CameraComponent) error {
-	scrWidth, scrHeight := r.renderer.context.ScreenSize()
-	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, r.gBuffer)
+	scrWidth, scrHeight := r.target.width, r.target.height
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, r.g.fbo)
 	gl.BindFramebuffer(gl.DRAW_FRAMEBUFFER, targetFBO)
-	gl.BlitFramebuffer(0, 0, int32(scrWidth), int32(scrHeight), int32(float32(scrWidth)*camera.ViewportX), int32(float32(scrHeight)*camera.ViewportY), int32(float32(scrWidth)*camera.ViewportW), int32(float32(scrHeight)*camera.ViewportH), gl.DEPTH_BUFFER_BIT, gl.NEAREST)
+	gl.BlitFramebuffer(0, 0, int32(r.g.width), int32(r.g.height), int32(float32(scrWidth)*camera.ViewportX), int32(float32(scrHeight)*camera.ViewportY), int32(float32(scrWidth)*camera.ViewportW), int32(float32(scrHeight)*camera.ViewportH), gl.DEPTH_BUFFER_BIT, gl.NEAREST)
 	gl.BindFramebuffer(gl.FRAMEBUFFER, targetFBO)
 	return nil
 }
 
 func (r *deferredShading) spritePass(sprites []*SpriteComponent, camera *CameraComponent) error {
-	scrWidth, scrHeight := r.renderer.context.ScreenSize()
+	scrWidth, scrHeight := r.target.width, r.target.height
 	cameraObj := camera.Object()
 	view := mgl32.LookAtV(cameraObj.Position(), cameraObj.Position().Add(cameraObj.Forward()), cameraObj.Up())
 	aspect := (camera.ViewportW * float32(scrWidth)) / (camera.ViewportH * float32(scrHeight))
@@ -606,7 +756,7 @@ func (r *deferredShading) spritePass(sprites []*SpriteComponent, camera *CameraC
 			}
 			continue
 		}
-		shader := defaultShaders["sprite"]
+		shader := r.renderer.defaultShader("sprite")
 		if shader == nil {
 			continue
 		}
@@ -617,8 +767,16 @@ func (r *deferredShading) spritePass(sprites []*SpriteComponent, camera *CameraC
 		gl.UniformMatrix4fv(shader.getLocation("view"), 1, false, &view[0])
 		gl.UniformMatrix4fv(shader.getLocation("projection"), 1, false, &projection[0])
 
+		texture := material.texture
+		if material.RenderTexture != "" {
+			renderTexture, err := r.renderer.renderTexture(material.RenderTexture)
+			if err != nil {
+				return err
+			}
+			texture = renderTexture.texture
+		}
 		gl.ActiveTexture(gl.TEXTURE0)
-		gl.BindTexture(gl.TEXTURE_2D, material.texture)
+		gl.BindTexture(gl.TEXTURE_2D, texture)
 		gl.Uniform1i(shader.getLocation("textureMap"), 0)
 
 		if err := r.renderer.drawMesh(rMesh); err != nil {
@@ -671,8 +829,7 @@ func (r *deferredShading) generateDirLightShadowMap(shader *glShaderProgram, lig
 		}
 	}
 
-	scrWidth, scrHeight := r.renderer.context.ScreenSize()
-	gl.Viewport(int32(float32(scrWidth)*camera.ViewportX), int32(float32(scrHeight)*camera.ViewportY), int32(float32(scrWidth)*camera.ViewportW), int32(float32(scrHeight)*camera.ViewportH))
+	gl.Viewport(0, 0, int32(r.g.width), int32(r.g.height))
 	return &lightMatrix, nil
 }
 
@@ -727,8 +884,7 @@ func (r *deferredShading) generatePointLightShadowMap(shader *glShaderProgram, l
 		}
 	}
 
-	scrWidth, scrHeight := r.renderer.context.ScreenSize()
-	gl.Viewport(int32(float32(scrWidth)*camera.ViewportX), int32(float32(scrHeight)*camera.ViewportY), int32(float32(scrWidth)*camera.ViewportW), int32(float32(scrHeight)*camera.ViewportH))
+	gl.Viewport(0, 0, int32(r.g.width), int32(r.g.height))
 	return nil
 }
 
@@ -772,8 +928,7 @@ func (r *deferredShading) generateSpotLightShadowMap(shader *glShaderProgram, li
 		}
 	}
 
-	scrWidth, scrHeight := r.renderer.context.ScreenSize()
-	gl.Viewport(int32(float32(scrWidth)*camera.ViewportX), int32(float32(scrHeight)*camera.ViewportY), int32(float32(scrWidth)*camera.ViewportW), int32(float32(scrHeight)*camera.ViewportH))
+	gl.Viewport(0, 0, int32(r.g.width), int32(r.g.height))
 	return &lightMatrix, nil
 }
 
@@ -787,10 +942,14 @@ func (r *deferredShading) selectMeshShader(mesh *MeshComponent) (*glShaderProgra
 		}
 	}
 	material := r.renderer.meshMaterials[mesh.Material]
-	if material.diffuseMap != 0 {
-		return defaultShaders["mesh_texture_deferred"], nil
+	diffuseTexture, err := r.renderer.diffuseTexture(material)
+	if err != nil {
+		return nil, err
+	}
+	if diffuseTexture != 0 {
+		return r.renderer.defaultShader("mesh_texture_deferred"), nil
 	} else {
-		return defaultShaders["mesh_color_deferred"], nil
+		return r.renderer.defaultShader("mesh_color_deferred"), nil
 	}
 }
 
@@ -819,12 +978,28 @@ func (r *deferredShading) applyShaderToMesh(shader *glShaderProgram, uniform def
 		gl.Uniform1f(shader.getLocation("recvShadow"), 0)
 	}
 
-	if material.installed() && material.diffuseMap != 0 {
+	diffuseTexture, err := r.renderer.diffuseTexture(material)
+	if err != nil {
+		return err
+	}
+	if material.installed() && diffuseTexture != 0 {
 		gl.ActiveTexture(gl.TEXTURE0)
-		gl.BindTexture(gl.TEXTURE_2D, material.diffuseMap)
+		gl.BindTexture(gl.TEXTURE_2D, diffuseTexture)
 		gl.Uniform1i(shader.getLocation("diffuseMap"), 0)
+		// float render textures hold linear colors already
+		if material.RenderTexture != "" && r.renderer.renderTextures[material.RenderTexture].linear() {
+			gl.Uniform1i(shader.getLocation("decode"), 0)
+		} else {
+			gl.Uniform1i(shader.getLocation("decode"), 1)
+		}
 	} else {
-		gl.Uniform4fv(shader.getLocation("color"), 1, &material.DiffuseColor[0])
+		color := mgl32.Vec4{
+			SRGBToLinear(material.DiffuseColor.X()),
+			SRGBToLinear(material.DiffuseColor.Y()),
+			SRGBToLinear(material.DiffuseColor.Z()),
+			material.DiffuseColor.W(),
+		}
+		gl.Uniform4fv(shader.getLocation("color"), 1, &color[0])
 	}
 
 	return nil
@@ -0,0 +1,127 @@
+package opengl
+
+import (
+	"github.com/go-gl/gl/v3.2-core/gl"
+	"github.com/go-gl/mathgl/mgl32"
+	. "github.com/wxdao/wengine"
+)
+
+// exposureMeterSize is the largest side of the mip level of the HDR buffer
+// read back for metering.
+const exposureMeterSize = 64
+
+// exposureMeter measures the luminance a camera sees for auto exposure. The
+// HDR buffer is mipmapped down and a small level is read back
+// asynchronously, so the exposure adapts to what was drawn a frame or so
+// earlier.
+type exposureMeter struct {
+	fbo   uint32
+	pbo   uint32
+	fence uintptr
+	// width and height are the size of the reading in flight
+	width, height int
+	// elapsed is the unscaled time since the exposure was last adapted
+	elapsed float64
+}
+
+// meter returns the exposure meter of camera, creating it on first use.
+func (r *deferredShading) meter(camera *CameraComponent) *exposureMeter {
+	if m, exists := r.meters[camera]; exists {
+		return m
+	}
+	m := newExposureMeter()
+	r.meters[camera] = m
+	return m
+}
+
+// frameEnd drops the exposure meters of cameras not rendered in the frame,
+// such as those of destroyed objects.
+func (r *deferredShading) frameEnd(cameras []*CameraComponent) {
+	rendered := map[*CameraComponent]bool{}
+	for _, camera := range cameras {
+		rendered[camera] = true
+	}
+	for camera, m := range r.meters {
+		if !rendered[camera] {
+			m.delete()
+			delete(r.meters, camera)
+		}
+	}
+}
+
+func newExposureMeter() *exposureMeter {
+	m := &exposureMeter{}
+
+	// the HDR buffer level to read is attached on measure
+	gl.GenFramebuffers(1, &m.fbo)
+
+	gl.GenBuffers(1, &m.pbo)
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, m.pbo)
+	gl.BufferData(gl.PIXEL_PACK_BUFFER, exposureMeterSize*exposureMeterSize*4*4, nil, gl.STREAM_READ)
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, 0)
+
+	return m
+}
+
+// measure adapts the exposure of camera to the last reading if the GPU is
+// done with it, and starts a new reading of g unless one is in flight. The
+// HDR buffer of g holds just the viewport of camera.
+func (m *exposureMeter) measure(g *gBuffer, camera *CameraComponent, deltaTime float64) {
+	m.elapsed += deltaTime
+	if m.fence != 0 {
+		status := gl.ClientWaitSync(m.fence, 0, 0)
+		if status == gl.TIMEOUT_EXPIRED {
+			return
+		}
+		gl.DeleteSync(m.fence)
+		m.fence = 0
+		if status != gl.WAIT_FAILED {
+			pixels := make([]float32, m.width*m.height*4)
+			gl.BindBuffer(gl.PIXEL_PACK_BUFFER, m.pbo)
+			gl.GetBufferSubData(gl.PIXEL_PACK_BUFFER, 0, len(pixels)*4, gl.Ptr(pixels))
+			gl.BindBuffer(gl.PIXEL_PACK_BUFFER, 0)
+
+			histogram := LuminanceHistogram{}
+			for i := 0; i+2 < len(pixels); i += 4 {
+				histogram.Add(mgl32.Vec3{pixels[i], pixels[i+1], pixels[i+2]})
+			}
+			camera.AdaptExposure(&histogram, m.elapsed)
+			m.elapsed = 0
+		}
+	}
+
+	// every level averages 2x2 texels of the one above, down to the first
+	// one that fits the meter
+	level := 0
+	m.width, m.height = g.width, g.height
+	for m.width > exposureMeterSize || m.height > exposureMeterSize {
+		level++
+		if m.width > 1 {
+			m.width /= 2
+		}
+		if m.height > 1 {
+			m.height /= 2
+		}
+	}
+	gl.BindTexture(gl.TEXTURE_2D, g.hdr)
+	gl.GenerateMipmap(gl.TEXTURE_2D)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+
+	gl.BindFramebuffer(gl.READ_FRAMEBUFFER, m.fbo)
+	gl.FramebufferTexture2D(gl.READ_FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, g.hdr, int32(level))
+	gl.ReadBuffer(gl.COLOR_ATTACHMENT0)
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, m.pbo)
+	gl.ReadPixels(0, 0, int32(m.width), int32(m.height), gl.RGBA, gl.FLOAT, gl.PtrOffset(0))
+	gl.BindBuffer(gl.PIXEL_PACK_BUFFER, 0)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+
+	m.fence = gl.FenceSync(gl.SYNC_GPU_COMMANDS_COMPLETE, 0)
+}
+
+func (m *exposureMeter) delete() {
+	gl.DeleteFramebuffers(1, &m.fbo)
+	gl.DeleteBuffers(1, &m.pbo)
+	if m.fence != 0 {
+		gl.DeleteSync(m.fence)
+	}
+}
@@ -9,6 +9,9 @@ import (
 	"math"
 )
 
+// forwardShading draws straight into the target, with no HDR buffer to
+// resolve: cameras with exposure, tone mapping or auto exposure are rejected,
+// as only deferredShading applies them.
 type forwardShading struct {
 	renderer *renderer
 }
@@ -17,16 +20,27 @@ func (r *forwardShading) init() error {
 	return nil
 }
 
-func (r *forwardShading) render(targetFBO uint32, lights []*LightComponent, meshes []*MeshComponent, sprites []*SpriteComponent, scene *Scene, camera *CameraComponent) error {
-	err := r.scenePass(targetFBO, lights, meshes, scene, camera)
+func (r *forwardShading) resize(width, height int) error {
+	return nil
+}
+
+func (r *forwardShading) frameEnd(cameras []*CameraComponent) {
+}
+
+func (r *forwardShading) render(target renderTarget, lights []*LightComponent, meshes []*MeshComponent, sprites []*SpriteComponent, scene *Scene, camera *CameraComponent) error {
+	if camera.Exposure != 0 || camera.ToneMapping != TONE_MAPPING_NONE || camera.AutoExposure {
+		return errors.New("exposure and tone mapping need deferred shading")
+	}
+	err := r.scenePass(target, lights, meshes, scene, camera)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r *forwardShading) scenePass(targetFBO uint32, lights []*LightComponent, meshes []*MeshComponent, scene *Scene, camera *CameraComponent) error {
-	gl.BindFramebuffer(gl.FRAMEBUFFER, targetFBO)
+func (r *forwardShading) scenePass(target renderTarget, lights []*LightComponent, meshes []*MeshComponent, scene *Scene, camera *CameraComponent) error {
+	gl.BindFramebuffer(gl.FRAMEBUFFER, target.fbo)
+	gl.Viewport(0, 0, int32(target.width), int32(target.height))
 	gl.ClearColor(
 		camera.Ambient.X(),
 		camera.Ambient.Y(),
@@ -37,7 +51,7 @@ func (r *forwardShading) scenePass(targetFBO uint32, lights []*LightComponent, m
 	// build view & projection matrices
 	cameraObj := camera.Object()
 	view := mgl32.LookAtV(cameraObj.Position(), cameraObj.Position().Add(cameraObj.Forward()), cameraObj.Up())
-	scrWidth, scrHeight := r.renderer.context.ScreenSize()
+	scrWidth, scrHeight := target.width, target.height
 	var projection mgl32.Mat4
 	switch camera.Mode {
 	case CAMERA_MODE_PERSPECTIVE:
@@ -139,16 +153,20 @@ func (r *forwardShading) selectShader(mesh *MeshComponent, lights []*LightCompon
 	}
 	hasLights := len(lights) > 0
 	material := r.renderer.meshMaterials[mesh.Material]
-	if material.diffuseMap != 0 {
+	diffuseTexture, err := r.renderer.diffuseTexture(material)
+	if err != nil {
+		return nil, err
+	}
+	if diffuseTexture != 0 {
 		if hasLights {
-			return defaultShaders["mesh_texture"], nil
+			return r.renderer.defaultShader("mesh_texture"), nil
 		}
-		return defaultShaders["mesh_texture_nolight"], nil
+		return r.renderer.defaultShader("mesh_texture_nolight"), nil
 	} else {
 		if hasLights {
-			return defaultShaders["mesh_color"], nil
+			return r.renderer.defaultShader("mesh_color"), nil
 		}
-		return defaultShaders["mesh_color_nolight"], nil
+		return r.renderer.defaultShader("mesh_color_nolight"), nil
 	}
 }
 
@@ -190,6 +208,10 @@ func (r *forwardShading) applyShaderToMesh(shader *glShaderProgram, uniform forw
 	projectionLoc := gl.GetUniformLocation(shader.program, gl.Str("projection\x00"))
 	cameraPositionLoc := gl.GetUniformLocation(shader.program, gl.Str("cameraPosition\x00"))
 	material := r.renderer.meshMaterials[mesh.Material]
+	diffuseTexture, err := r.renderer.diffuseTexture(material)
+	if err != nil {
+		return err
+	}
 
 	gl.UseProgram(shader.program)
 
@@ -198,9 +220,9 @@ func (r *forwardShading) applyShaderToMesh(shader *glShaderProgram, uniform forw
 	gl.UniformMatrix4fv(projectionLoc, 1, false, &uniform.projection[0])
 	gl.UniformMatrix3fv(cameraPositionLoc, 1, false, &uniform.cameraPosition[0])
 
-	if material.diffuseMap != 0 {
+	if diffuseTexture != 0 {
 		gl.ActiveTexture(gl.TEXTURE0)
-		gl.BindTexture(gl.TEXTURE_2D, material.diffuseMap)
+		gl.BindTexture(gl.TEXTURE_2D, diffuseTexture)
 		gl.Uniform1i(gl.GetUniformLocation(shader.program, gl.Str("diffuseMap\x00")), 0)
 	} else {
 		gl.Uniform4fv(gl.GetUniformLocation(shader.program, gl.Str("color\x00")), 1, &material.DiffuseColor[0])
@@ -6,12 +6,11 @@ import (
 	"github.com/go-gl/gl/v3.2-core/gl"
 	. "github.com/wxdao/wengine"
 	"image"
-	"sort"
 	"strings"
 )
 
 func init() {
-	RegisterRenderer("opengl", newRenderer())
+	RegisterRendererFactory("opengl", func() Renderer { return newRenderer() })
 }
 
 type renderer struct {
@@ -20,8 +19,12 @@ type renderer struct {
 	meshes          map[string]*glMesh
 	meshMaterials   map[string]*glMeshMaterial
 	spriteMaterials map[string]*glSpriteMaterial
+	renderTextures  map[string]*glRenderTexture
 	programs        map[string]*glShaderProgram
 
+	// offscreen replaces the window as the screen when set
+	offscreen *glRenderTexture
+
 	dirLightShadowMapResolution   int
 	pointLightShadowMapResolution int
 	spotLightShadowMapResolution  int
@@ -29,6 +32,7 @@ type renderer struct {
 	pc renderPath
 
 	assetsToInstall []string
+	pendingCaptures []*pendingCapture
 
 	lastScene *Scene
 
@@ -39,6 +43,8 @@ func newRenderer() *renderer {
 	r := &renderer{
 		meshes:                        map[string]*glMesh{},
 		meshMaterials:                 map[string]*glMeshMaterial{},
+		spriteMaterials:               map[string]*glSpriteMaterial{},
+		renderTextures:                map[string]*glRenderTexture{},
 		programs:                      map[string]*glShaderProgram{},
 		dirLightShadowMapResolution:   3072,
 		pointLightShadowMapResolution: 512,
@@ -74,7 +80,10 @@ func (r *renderer) Init(context *Context) error {
 }
 
 func (r *renderer) loadDefaultShaders() error {
-	for name, shader := range defaultShaders {
+	for name, source := range defaultShaders {
+		// programs belong to the GL context of the renderer, so every
+		// renderer installs its own
+		shader := &glShaderProgram{vertexSource: source.vertexSource, geometrySource: source.geometrySource, fragmentSource: source.fragmentSource}
 		if err := shader.install(); err != nil {
 			return err
 		}
@@ -85,11 +94,16 @@ func (r *renderer) loadDefaultShaders() error {
 	return nil
 }
 
+func (r *renderer) defaultShader(name string) *glShaderProgram {
+	return r.programs["___"+name]
+}
+
 func (r *renderer) Version() string {
 	return r.versionStr
 }
 
 func (r *renderer) Render(scene *Scene) error {
+	r.pollCaptures(false)
 	r.installAll()
 	// find all cameras
 	cameras := []*CameraComponent{}
@@ -97,35 +111,121 @@ func (r *renderer) Render(scene *Scene) error {
 	sprites := []*SpriteComponent{}
 	lights := []*LightComponent{}
 	for _, obj := range scene.Objects() {
-		if !obj.Enabled() {
+		if !obj.ActiveInHierarchy() {
 			continue
 		}
-		for _, compo := range obj.Components() {
-			switch c := compo.(type) {
-			case *CameraComponent:
-				cameras = append(cameras, c)
-			case *MeshComponent:
-				meshes = append(meshes, c)
-			case *LightComponent:
-				lights = append(lights, c)
-			case *SpriteComponent:
-				sprites = append(sprites, c)
+		for _, compos := range obj.Components() {
+			for _, compo := range compos {
+				switch c := compo.(type) {
+				case *CameraComponent:
+					cameras = append(cameras, c)
+				case *MeshComponent:
+					meshes = append(meshes, c)
+				case *LightComponent:
+					lights = append(lights, c)
+				case *SpriteComponent:
+					sprites = append(sprites, c)
+				}
 			}
 		}
 	}
-	// sort by depth, decreasing
-	sort.Slice(cameras, func(i, j int) bool {
-		return !(cameras[i].Depth < cameras[j].Depth)
-	})
+	SortCameras(cameras, meshes, sprites, r.context.Assets())
 	// hand over to renderPath
 	for _, camera := range cameras {
-		if err := r.pc.render(0, lights, meshes, sprites, scene, camera); err != nil {
+		target := r.screenTarget()
+		if camera.TargetTexture != "" {
+			texture, err := r.renderTexture(camera.TargetTexture)
+			if err != nil {
+				return err
+			}
+			target = renderTarget{fbo: texture.fbo, width: texture.width, height: texture.height, linear: texture.linear()}
+		}
+		cameraMeshes, cameraSprites := SkipTargetSamplers(camera, meshes, sprites, r.context.Assets())
+		if err := r.pc.render(target, lights, cameraMeshes, cameraSprites, scene, camera); err != nil {
 			return err
 		}
 	}
+	r.pc.frameEnd(cameras)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+	return nil
+}
+
+// renderTexture returns the named render texture, installing it, or
+// reinstalling it if its size or format changed.
+func (r *renderer) renderTexture(name string) (*glRenderTexture, error) {
+	asset, ok := r.context.Assets()[name].(*RenderTextureAsset)
+	if !ok {
+		return nil, errors.New("no such render texture: " + name)
+	}
+	texture, exists := r.renderTextures[name]
+	if exists && texture.installed() {
+		return texture, nil
+	}
+	if exists {
+		texture.uninstall()
+	} else {
+		texture = &glRenderTexture{RenderTextureAsset: asset}
+		r.renderTextures[name] = texture
+	}
+	if err := texture.install(); err != nil {
+		return nil, err
+	}
+	return texture, nil
+}
+
+// diffuseTexture returns the texture a mesh material samples, its render
+// texture or its diffuse map, or 0 to use the diffuse color.
+func (r *renderer) diffuseTexture(material *glMeshMaterial) (uint32, error) {
+	if material.RenderTexture != "" {
+		texture, err := r.renderTexture(material.RenderTexture)
+		if err != nil {
+			return 0, err
+		}
+		return texture.texture, nil
+	}
+	return material.diffuseMap, nil
+}
+
+func (r *renderer) Resize(width, height int) error {
+	if r.offscreen != nil {
+		r.offscreen.Width, r.offscreen.Height = width, height
+		r.offscreen.uninstall()
+		if err := r.offscreen.install(); err != nil {
+			return err
+		}
+	}
+	return r.pc.resize(width, height)
+}
+
+// SetOffscreen makes the screen an offscreen framebuffer of the screen size
+// instead of the window's, to render without showing it.
+func (r *renderer) SetOffscreen(offscreen bool) error {
+	if r.offscreen != nil {
+		r.offscreen.uninstall()
+		r.offscreen = nil
+	}
+	if !offscreen {
+		return nil
+	}
+	scrWidth, scrHeight := r.context.ScreenSize()
+	texture := &glRenderTexture{RenderTextureAsset: &RenderTextureAsset{Width: scrWidth, Height: scrHeight}}
+	if err := texture.install(); err != nil {
+		return err
+	}
+	r.offscreen = texture
 	return nil
 }
 
+// screenTarget is what cameras without a target texture draw into.
+func (r *renderer) screenTarget() renderTarget {
+	if r.offscreen != nil {
+		return renderTarget{fbo: r.offscreen.fbo, width: r.offscreen.width, height: r.offscreen.height}
+	}
+	target := renderTarget{}
+	target.width, target.height = r.context.ScreenSize()
+	return target
+}
+
 func (r *renderer) NotifyInstall(assets []string) error {
 	r.assetsToInstall = append(r.assetsToInstall, assets...)
 	return nil
@@ -176,6 +276,15 @@ func (r *renderer) installAll() error {
 				return err
 			}
 			println("installed material: " + name)
+		case *SpriteMaterialAsset:
+			if _, exists := r.spriteMaterials[name]; exists {
+				continue
+			}
+			r.spriteMaterials[name] = &glSpriteMaterial{SpriteMaterialAsset: a}
+			if err := r.spriteMaterials[name].install(); err != nil {
+				return err
+			}
+			println("installed material: " + name)
 		}
 	}
 	r.assetsToInstall = []string{}
@@ -295,7 +404,7 @@ type glSpriteMaterial struct {
 }
 
 func (m *glSpriteMaterial) installed() bool {
-	if m.texture == 0 {
+	if m.texture == 0 && m.RenderTexture == "" {
 		return false
 	}
 	return true
@@ -429,3 +538,82 @@ func compileShader(source string, shaderType uint32) (uint32, error) {
 	}
 	return shader, nil
 }
+
+// -----------------------------------------------------------
+
+type glRenderTexture struct {
+	*RenderTextureAsset
+
+	// what was installed, to notice changes of the asset
+	width, height, format int
+
+	fbo     uint32
+	texture uint32
+	depth   uint32
+}
+
+func (t *glRenderTexture) installed() bool {
+	return t.fbo != 0 && t.width == t.Width && t.height == t.Height && t.format == t.Format
+}
+
+// linear tells float textures, which hold linear colors, from 8-bit ones,
+// which hold sRGB encoded colors.
+func (t *glRenderTexture) linear() bool {
+	return t.format != TEXTURE_FORMAT_RGBA8
+}
+
+func (t *glRenderTexture) install() error {
+	if t.Width <= 0 || t.Height <= 0 {
+		return errors.New("invalid render texture size")
+	}
+	var internalFormat int32
+	var pixelType uint32
+	switch t.Format {
+	case TEXTURE_FORMAT_RGBA8:
+		internalFormat, pixelType = gl.RGBA8, gl.UNSIGNED_BYTE
+	case TEXTURE_FORMAT_RGBA16F:
+		internalFormat, pixelType = gl.RGBA16F, gl.FLOAT
+	case TEXTURE_FORMAT_RGBA32F:
+		internalFormat, pixelType = gl.RGBA32F, gl.FLOAT
+	default:
+		return errors.New("invalid render texture format")
+	}
+
+	gl.GenFramebuffers(1, &t.fbo)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, t.fbo)
+
+	gl.GenTextures(1, &t.texture)
+	gl.BindTexture(gl.TEXTURE_2D, t.texture)
+	gl.TexImage2D(gl.TEXTURE_2D, 0, internalFormat, int32(t.Width), int32(t.Height), 0, gl.RGBA, pixelType, nil)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MIN_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_MAG_FILTER, gl.LINEAR)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_S, gl.CLAMP_TO_EDGE)
+	gl.TexParameteri(gl.TEXTURE_2D, gl.TEXTURE_WRAP_T, gl.CLAMP_TO_EDGE)
+	gl.FramebufferTexture2D(gl.FRAMEBUFFER, gl.COLOR_ATTACHMENT0, gl.TEXTURE_2D, t.texture, 0)
+
+	// same depth format as the gBuffer, so that its depth can be blitted
+	gl.GenRenderbuffers(1, &t.depth)
+	gl.BindRenderbuffer(gl.RENDERBUFFER, t.depth)
+	gl.RenderbufferStorage(gl.RENDERBUFFER, gl.DEPTH_COMPONENT, int32(t.Width), int32(t.Height))
+	gl.FramebufferRenderbuffer(gl.FRAMEBUFFER, gl.DEPTH_ATTACHMENT, gl.RENDERBUFFER, t.depth)
+
+	status := gl.CheckFramebufferStatus(gl.FRAMEBUFFER)
+
+	gl.BindRenderbuffer(gl.RENDERBUFFER, 0)
+	gl.BindTexture(gl.TEXTURE_2D, 0)
+	gl.BindFramebuffer(gl.FRAMEBUFFER, 0)
+
+	if status != gl.FRAMEBUFFER_COMPLETE {
+		t.uninstall()
+		return errors.New("framebuffer failed")
+	}
+	t.width, t.height, t.format = t.Width, t.Height, t.Format
+	return nil
+}
+
+func (t *glRenderTexture) uninstall() {
+	gl.DeleteFramebuffers(1, &t.fbo)
+	gl.DeleteTextures(1, &t.texture)
+	gl.DeleteRenderbuffers(1, &t.depth)
+	t.fbo, t.texture, t.depth = 0, 0, 0
+}
@@ -4,7 +4,20 @@ import (
 	. "github.com/wxdao/wengine"
 )
 
+// renderTarget is the framebuffer a camera draws into, the screen or a
+// render texture.
+type renderTarget struct {
+	fbo           uint32
+	width, height int
+	// linear targets get tone mapped colors without sRGB encoding
+	linear bool
+}
+
 type renderPath interface {
 	init() error
-	render(targetFBO uint32, lights []*LightComponent, meshes []*MeshComponent, sprites []*SpriteComponent, scene *Scene, camera *CameraComponent) error
+	// resize reallocates buffers that depend on the screen size
+	resize(width, height int) error
+	render(target renderTarget, lights []*LightComponent, meshes []*MeshComponent, sprites []*SpriteComponent, scene *Scene, camera *CameraComponent) error
+	// frameEnd drops what is kept for cameras not rendered in the frame
+	frameEnd(cameras []*CameraComponent)
 }
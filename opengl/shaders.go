@@ -349,13 +349,18 @@ var defaultShaders = map[string]*glShaderProgram{
 		in vec3 vs_fragPosition;
 
 		uniform sampler2D diffuseMap;
+		uniform bool decode = true;
 
 		uniform float recvShadow = 0.0;
 
 		void main() {
+			vec3 diffuse = texture(diffuseMap, vs_uv).rgb;
+			if (decode) {
+				diffuse = mix(diffuse / 12.92, pow((diffuse + 0.055) / 1.055, vec3(2.4)), step(0.04045, diffuse));
+			}
 			gPosition = vs_fragPosition;
 			gNormal = vs_normal;
-			gDiffuse = vec4(texture(diffuseMap, vs_uv).rgb, recvShadow);
+			gDiffuse = vec4(diffuse, recvShadow);
 		}
 	`,
 	},
@@ -390,6 +395,56 @@ var defaultShaders = map[string]*glShaderProgram{
 	`,
 	},
 
+	"deferred_tonemap": {
+		vertexSource: `
+		#version 410 core
+
+		layout (location = 0) in vec3 position;
+		layout (location = 1) in vec2 uv;
+
+		out vec2 vs_uv;
+
+		void main() {
+			vs_uv = uv;
+			gl_Position = vec4(position, 1.0);
+		}
+	`,
+		fragmentSource: `
+		#version 410 core
+
+		in vec2 vs_uv;
+
+		uniform sampler2D hdr;
+		uniform float exposure;
+		uniform int toneMapping;
+		uniform bool encode;
+
+		out vec4 color;
+
+		vec3 hable(vec3 x) {
+			const float a = 0.15, b = 0.50, c = 0.10, d = 0.20, e = 0.02, f = 0.30;
+			return (x * (a * x + c * b) + d * e) / (x * (a * x + b) + d * f) - e / f;
+		}
+
+		void main() {
+			vec3 result = exposure * texture(hdr, vs_uv).rgb;
+			// TONE_MAPPING_REINHARD, TONE_MAPPING_ACES, TONE_MAPPING_FILMIC
+			if (toneMapping == 1) {
+				result = result / (1.0 + result);
+			} else if (toneMapping == 2) {
+				result = (result * (2.51 * result + 0.03)) / (result * (2.43 * result + 0.59) + 0.14);
+			} else if (toneMapping == 3) {
+				result = hable(result * 2.0) / hable(vec3(11.2));
+			}
+			if (encode) {
+				result = clamp(result, 0.0, 1.0);
+				result = mix(result * 12.92, 1.055 * pow(result, vec3(1.0 / 2.4)) - 0.055, step(0.0031308, result));
+			}
+			color = vec4(result, 1.0);
+		}
+	`,
+	},
+
 	"deferred_dirLight": {
 		vertexSource: `
 		#version 410 core
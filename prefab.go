@@ -0,0 +1,122 @@
+package wengine
+
+import (
+	"errors"
+	"os"
+	"reflect"
+)
+
+// BehaviorCloner can be implemented by a behavior to control how it is
+// copied when its object is cloned. Behaviors that do not implement it are
+// copied field by field, sharing the slices, maps and pointers they hold
+// with the original.
+type BehaviorCloner interface {
+	CloneBehavior() Behavior
+}
+
+// ComponentCloner is BehaviorCloner for components. The object of the
+// returned component is set by Clone.
+type ComponentCloner interface {
+	CloneComponent() Component
+}
+
+// Prefab is a template object tree that can be instantiated many times.
+type Prefab struct {
+	root *Object
+}
+
+func NewPrefab(root *Object) *Prefab {
+	return &Prefab{root: root}
+}
+
+// LoadPrefabFile reads a prefab from a scene file holding exactly one root
+// object.
+func LoadPrefabFile(path string) (*Prefab, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scene, err := ReadScene(file)
+	if err != nil {
+		return nil, err
+	}
+	var root *Object
+	for _, obj := range scene.objects {
+		if obj.parent != nil {
+			continue
+		}
+		if root != nil {
+			return nil, errors.New("prefab file has more than one root object")
+		}
+		root = obj
+	}
+	if root == nil {
+		return nil, errors.New("prefab file has no object")
+	}
+	return NewPrefab(root), nil
+}
+
+func (p *Prefab) Root() *Object {
+	return p.root
+}
+
+// InstantiatePrefab clones the prefab into the scene under name. Overrides
+// are applied to the cloned root, in order, before it is registered.
+func (s *Scene) InstantiatePrefab(name string, prefab *Prefab, overrides ...func(instance *Object)) *Object {
+	instance := prefab.root.Clone()
+	for _, override := range overrides {
+		override(instance)
+	}
+	s.Instantiate(name, instance)
+	return instance
+}
+
+// Clone copies the object with its components, behaviors and children.
+// Components and behaviors are copied with ComponentCloner and
+// BehaviorCloner if they implement them, and field by field otherwise. The
+// clone has no parent and is not registered in any scene.
+func (o *Object) Clone() *Object {
+	clone := NewObject()
+	clone.name = o.name
+	clone.SetEnabled(o.enabled)
+	clone.localPosition = o.localPosition
+	clone.localRotation = o.localRotation
+	clone.localScale = o.localScale
+
+	for _, compo := range o.attached {
+		clone.AttachComponent(cloneComponent(compo))
+	}
+
+	for _, slot := range o.behaviors {
+		if cloner, ok := slot.behavior.(BehaviorCloner); ok {
+			clone.AddBehavior(cloner.CloneBehavior())
+		} else {
+			clone.AddBehavior(shallowCopy(slot.behavior).(Behavior))
+		}
+	}
+
+	for _, child := range o.children {
+		child.Clone().SetParent(clone, false)
+	}
+	return clone
+}
+
+func cloneComponent(compo Component) Component {
+	if cloner, ok := compo.(ComponentCloner); ok {
+		return cloner.CloneComponent()
+	}
+	return shallowCopy(compo).(Component)
+}
+
+// shallowCopy copies the value a pointer points to into a new one, and
+// returns everything else as it is.
+func shallowCopy(v interface{}) interface{} {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return v
+	}
+	copied := reflect.New(value.Elem().Type())
+	copied.Elem().Set(value.Elem())
+	return copied.Interface()
+}
@@ -0,0 +1,125 @@
+package wengine
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	"testing"
+)
+
+var waypointsComponentType = NewComponentType()
+
+// waypointsComponent holds a slice, which the clones must not share.
+type waypointsComponent struct {
+	Points []mgl32.Vec3
+
+	ComponentBase
+}
+
+func (waypointsComponent) Type() int {
+	return waypointsComponentType
+}
+
+func (c *waypointsComponent) CloneComponent() Component {
+	return &waypointsComponent{Points: append([]mgl32.Vec3(nil), c.Points...)}
+}
+
+type healthBehavior struct {
+	Health int
+}
+
+func (b *healthBehavior) Start(bctx *BehaviorContext)  {}
+func (b *healthBehavior) Update(bctx *BehaviorContext) {}
+
+// newPrefab makes an enabled root with a light, a waypoints component and a
+// health behavior, and a disabled child with a mesh.
+func newPrefab() *Prefab {
+	root := NewObject()
+	root.SetEnabled(true)
+	root.SetLocalPosition(mgl32.Vec3{1, 2, 3})
+	root.AttachComponent(&LightComponent{Range: 4})
+	root.AttachComponent(&waypointsComponent{Points: []mgl32.Vec3{{1, 0, 0}}})
+	root.AddBehavior(&healthBehavior{Health: 10})
+	child := NewObject()
+	child.SetLocalScale(mgl32.Vec3{2, 2, 2})
+	child.AttachComponent(&MeshComponent{Mesh: "cube"})
+	child.SetParent(root, false)
+	return NewPrefab(root)
+}
+
+func TestClone(t *testing.T) {
+	prefab := newPrefab()
+	root := prefab.Root()
+	clone := root.Clone()
+
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"no parent", clone.Parent() == nil},
+		{"enabled", clone.Enabled() && clone.ActiveInHierarchy()},
+		{"position", clone.LocalPosition() == root.LocalPosition()},
+		{"one child", len(clone.Children()) == 1},
+		{"child parent", len(clone.Children()) == 1 && clone.Children()[0].Parent() == clone},
+		{"child disabled", len(clone.Children()) == 1 && !clone.Children()[0].Enabled()},
+		{"child scale", len(clone.Children()) == 1 && clone.Children()[0].LocalScale() == mgl32.Vec3{2, 2, 2}},
+	}
+	for _, test := range tests {
+		if !test.ok {
+			t.Error(test.name)
+		}
+	}
+
+	light, _ := GetComponent[*LightComponent](clone)
+	original, _ := GetComponent[*LightComponent](root)
+	if light == original || light.Range != 4 || light.Object() != clone {
+		t.Error("light component not copied")
+	}
+	light.Range = 8
+	if original.Range != 4 {
+		t.Error("light component shared")
+	}
+
+	waypoints, _ := GetComponent[*waypointsComponent](clone)
+	waypoints.Points[0] = mgl32.Vec3{9, 9, 9}
+	if originalWaypoints, _ := GetComponent[*waypointsComponent](root); originalWaypoints.Points[0] != (mgl32.Vec3{1, 0, 0}) {
+		t.Error("ComponentCloner not used")
+	}
+	if waypoints.Object() != clone {
+		t.Error("cloned component not attached to the clone")
+	}
+
+	health := clone.Behaviors()[0].(*healthBehavior)
+	health.Health = 1
+	if root.Behaviors()[0].(*healthBehavior).Health != 10 {
+		t.Error("behavior shared")
+	}
+}
+
+func TestInstantiatePrefab(t *testing.T) {
+	prefab := newPrefab()
+	scene := NewScene()
+	first := scene.InstantiatePrefab("enemy", prefab, func(instance *Object) {
+		instance.SetLocalPosition(mgl32.Vec3{5, 0, 0})
+	})
+	second := scene.InstantiatePrefab("enemy", prefab)
+
+	tests := []struct {
+		name   string
+		object *Object
+	}{
+		{"enemy", first},
+		{"enemy/0", first.Children()[0]},
+		{"enemy (1)", second},
+		{"enemy (1)/0", second.Children()[0]},
+	}
+	for _, test := range tests {
+		if scene.Objects()[test.name] != test.object {
+			t.Errorf("%q not registered", test.name)
+		}
+	}
+	if first.LocalPosition() != (mgl32.Vec3{5, 0, 0}) || second.LocalPosition() != prefab.Root().LocalPosition() {
+		t.Error("override not applied to the first instance only")
+	}
+	if prefab.Root().Name() == "enemy" {
+		t.Error("prefab root registered")
+	}
+}
@@ -1,5 +1,10 @@
 package wengine
 
+import (
+	"image"
+	"sort"
+)
+
 type Renderer interface {
 	Init(context *Context) error
 	Version() string
@@ -7,13 +12,143 @@ type Renderer interface {
 	NotifyInstall(assets []string) error
 }
 
+// ResizableRenderer can be implemented by a renderer to reallocate targets
+// that depend on the screen size. Resize is called before the next Render
+// after the screen size changed, never with a zero size.
+type ResizableRenderer interface {
+	Resize(width, height int) error
+}
+
+// OffscreenRenderer can be implemented by a renderer that normally draws
+// into a window, to draw the screen into an offscreen buffer of the screen
+// size instead.
+type OffscreenRenderer interface {
+	SetOffscreen(offscreen bool) error
+}
+
+// CapturingRenderer can be implemented by a renderer to read back what it
+// drew. A nil camera captures the whole screen, otherwise the viewport of
+// the camera in its target. Captures read the last Render, before the frame
+// is presented.
+type CapturingRenderer interface {
+	Capture(camera *CameraComponent) (*image.RGBA, error)
+	// CaptureAsync starts reading back without waiting for the GPU. done
+	// is called once the pixels arrived, at the latest by FinishCaptures.
+	CaptureAsync(camera *CameraComponent, done func(img *image.RGBA, err error)) error
+	// FinishCaptures waits for the asynchronous captures in flight and
+	// delivers them.
+	FinishCaptures() error
+}
+
 var (
-	registeredRenderers = map[string]Renderer{}
+	registeredRenderers = map[string]func() Renderer{}
 )
 
+func init() {
+	RegisterRendererFactory("null", func() Renderer { return NewNullRenderer() })
+}
+
+// RegisterRenderer makes a renderer available to NewContext under name. The
+// same renderer is handed to every context created with it; use
+// RegisterRendererFactory for renderers keeping per-context state.
 func RegisterRenderer(name string, renderer Renderer) {
-	registeredRenderers[name] = renderer
+	registeredRenderers[name] = func() Renderer { return renderer }
 }
 
+// RegisterRendererFactory makes a renderer available to NewContext under
+// name. factory is called for every context created with it, so that
+// contexts do not share renderer state.
+func RegisterRendererFactory(name string, factory func() Renderer) {
+	registeredRenderers[name] = factory
+}
+
+// defaultRendererNames is the order renderers are tried in when NewContext is
+// given none, or none of the given ones is registered.
+var defaultRendererNames = []string{"opengl", "null"}
+
 type RendererSetting struct {
 }
+
+// SkipTargetSamplers drops the meshes and sprites whose material samples
+// the render texture camera draws into, as a texture cannot be read while it
+// is drawn into.
+func SkipTargetSamplers(camera *CameraComponent, meshes []*MeshComponent, sprites []*SpriteComponent, assets AssetMap) ([]*MeshComponent, []*SpriteComponent) {
+	if camera.TargetTexture == "" {
+		return meshes, sprites
+	}
+	keptMeshes := make([]*MeshComponent, 0, len(meshes))
+	for _, mesh := range meshes {
+		if material, ok := assets[mesh.Material].(*MeshMaterialAsset); ok && material.RenderTexture == camera.TargetTexture {
+			continue
+		}
+		keptMeshes = append(keptMeshes, mesh)
+	}
+	keptSprites := make([]*SpriteComponent, 0, len(sprites))
+	for _, sprite := range sprites {
+		if material, ok := assets[sprite.Material].(*SpriteMaterialAsset); ok && material.RenderTexture == camera.TargetTexture {
+			continue
+		}
+		keptSprites = append(keptSprites, sprite)
+	}
+	return keptMeshes, keptSprites
+}
+
+// SortCameras sorts cameras in the order they are to be rendered: by depth,
+// decreasing, except that a camera drawing into a render texture comes
+// before the others when a material of meshes or sprites samples it, so the
+// texture is produced before it is consumed. Texture cameras depending on
+// each other in a cycle keep their depth order.
+func SortCameras(cameras []*CameraComponent, meshes []*MeshComponent, sprites []*SpriteComponent, assets AssetMap) {
+	sort.SliceStable(cameras, func(i, j int) bool {
+		return cameras[i].Depth > cameras[j].Depth
+	})
+
+	sampled := map[string]bool{}
+	for _, mesh := range meshes {
+		if material, ok := assets[mesh.Material].(*MeshMaterialAsset); ok && material.RenderTexture != "" {
+			sampled[material.RenderTexture] = true
+		}
+	}
+	for _, sprite := range sprites {
+		if material, ok := assets[sprite.Material].(*SpriteMaterialAsset); ok && material.RenderTexture != "" {
+			sampled[material.RenderTexture] = true
+		}
+	}
+	if len(sampled) == 0 {
+		return
+	}
+
+	// every camera draws every mesh, so it depends on all cameras drawing a
+	// sampled texture other than its own target
+	dependsOn := func(camera, producer *CameraComponent) bool {
+		return sampled[producer.TargetTexture] && producer.TargetTexture != camera.TargetTexture
+	}
+	remaining := append([]*CameraComponent{}, cameras...)
+	for i := range cameras {
+		next := -1
+		for j, camera := range remaining {
+			ready := true
+			for _, other := range remaining {
+				if dependsOn(camera, other) {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				next = j
+				break
+			}
+		}
+		if next < 0 {
+			// a cycle, the first texture camera in depth order goes
+			for j, camera := range remaining {
+				if sampled[camera.TargetTexture] {
+					next = j
+					break
+				}
+			}
+		}
+		cameras[i] = remaining[next]
+		remaining = append(remaining[:next], remaining[next+1:]...)
+	}
+}
@@ -0,0 +1,96 @@
+package wengine
+
+import "testing"
+
+// cameraAssets has render textures t1 and t2, and for each a mesh material
+// and a sprite material sampling it.
+var cameraAssets = AssetMap{
+	"t1":        &RenderTextureAsset{Width: 4, Height: 4},
+	"t2":        &RenderTextureAsset{Width: 4, Height: 4},
+	"mesh t1":   &MeshMaterialAsset{RenderTexture: "t1"},
+	"mesh t2":   &MeshMaterialAsset{RenderTexture: "t2"},
+	"sprite t1": &SpriteMaterialAsset{RenderTexture: "t1"},
+	"plain":     &MeshMaterialAsset{},
+}
+
+func TestSortCameras(t *testing.T) {
+	tests := []struct {
+		name      string
+		cameras   []string
+		materials []string
+		// sprite materials
+		spriteMaterials []string
+		want            []string
+	}{
+		{"by depth", []string{"main", "t1", "t2"}, []string{"plain"}, nil, []string{"main", "t1", "t2"}},
+		{"sampled texture first", []string{"main", "t1", "t2"}, []string{"mesh t1"}, nil, []string{"t1", "main", "t2"}},
+		{"sampled by a sprite", []string{"main", "t1", "t2"}, nil, []string{"sprite t1"}, []string{"t1", "main", "t2"}},
+		{"cycle keeps depth order", []string{"main", "t1", "t2"}, []string{"mesh t1", "mesh t2"}, nil, []string{"t1", "t2", "main"}},
+		{"unknown material", []string{"main", "t1"}, []string{"nope"}, nil, []string{"main", "t1"}},
+		{"no cameras", nil, []string{"mesh t1"}, nil, nil},
+	}
+	// main draws to the screen, the others to the texture of their name,
+	// depth decreasing in that order
+	depths := map[string]int{"main": 5, "t1": -1, "t2": -2}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cameras := []*CameraComponent{}
+			names := map[*CameraComponent]string{}
+			for _, name := range test.cameras {
+				camera := &CameraComponent{Depth: depths[name]}
+				if name != "main" {
+					camera.TargetTexture = name
+				}
+				cameras = append(cameras, camera)
+				names[camera] = name
+			}
+			meshes := []*MeshComponent{}
+			for _, material := range test.materials {
+				meshes = append(meshes, &MeshComponent{Material: material})
+			}
+			sprites := []*SpriteComponent{}
+			for _, material := range test.spriteMaterials {
+				sprites = append(sprites, &SpriteComponent{Material: material})
+			}
+
+			SortCameras(cameras, meshes, sprites, cameraAssets)
+			if len(cameras) != len(test.want) {
+				t.Fatalf("%d cameras, want %d", len(cameras), len(test.want))
+			}
+			for i, camera := range cameras {
+				if names[camera] != test.want[i] {
+					t.Errorf("camera %d = %s, want %s", i, names[camera], test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSkipTargetSamplers(t *testing.T) {
+	meshes := []*MeshComponent{{Material: "mesh t1"}, {Material: "mesh t2"}, {Material: "plain"}}
+	sprites := []*SpriteComponent{{Material: "sprite t1"}}
+	tests := []struct {
+		name        string
+		target      string
+		wantMeshes  int
+		wantSprites int
+	}{
+		{"screen", "", 3, 1},
+		{"t1", "t1", 2, 0},
+		{"t2", "t2", 2, 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			camera := &CameraComponent{TargetTexture: test.target}
+			gotMeshes, gotSprites := SkipTargetSamplers(camera, meshes, sprites, cameraAssets)
+			if len(gotMeshes) != test.wantMeshes || len(gotSprites) != test.wantSprites {
+				t.Errorf("%d meshes and %d sprites, want %d and %d", len(gotMeshes), len(gotSprites), test.wantMeshes, test.wantSprites)
+			}
+			for _, mesh := range gotMeshes {
+				if material := cameraAssets[mesh.Material].(*MeshMaterialAsset); test.target != "" && material.RenderTexture == test.target {
+					t.Errorf("mesh sampling %s kept", test.target)
+				}
+			}
+		})
+	}
+}
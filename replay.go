@@ -0,0 +1,550 @@
+package wengine
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// An input recording starts with inputRecordMagic and a version, followed by
+// a snapshot of the input state and one record per frame holding the input
+// time, the frame delta and the input events applied in the frame. Numbers
+// are little endian, counts and string lengths are uvarints.
+//
+// The window size is not recorded: it describes the machine the recording is
+// played on, not the input. Joysticks being connected or disconnected are,
+// and are replayed onto virtual devices standing in for the recorded ones.
+const (
+	inputRecordMagic   = "WINP"
+	inputRecordVersion = 1
+	// inputRecordMaxString bounds the strings read from a recording, which
+	// are key names and typed text.
+	inputRecordMaxString = 1 << 16
+)
+
+type inputRecorder struct {
+	writer *bufio.Writer
+	closer io.Closer
+}
+
+type inputPlayer struct {
+	reader *bufio.Reader
+	closer io.Closer
+	// eventsOnly keeps the frame times of the caller instead of the
+	// recorded ones
+	eventsOnly bool
+	// the live devices, put back when the replay ends
+	live  map[int]*joystickState
+	ended bool
+}
+
+// StartRecording records the input of every following frame, with its frame
+// delta, to w until StopRecording is called.
+func (ctx *Context) StartRecording(w io.Writer) error {
+	if ctx.recorder != nil {
+		return errors.New("already recording")
+	}
+	recorder := &inputRecorder{writer: bufio.NewWriter(w)}
+	if closer, ok := w.(io.Closer); ok {
+		recorder.closer = closer
+	}
+	recorder.writer.WriteString(inputRecordMagic)
+	recorder.writeUvarint(inputRecordVersion)
+	recorder.writeSnapshot(ctx.input)
+	ctx.recorder = recorder
+	return recorder.writer.Flush()
+}
+
+// StartRecordingFile is StartRecording to a file that is closed on
+// StopRecording.
+func (ctx *Context) StartRecordingFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := ctx.StartRecording(file); err != nil {
+		file.Close()
+		return err
+	}
+	return nil
+}
+
+func (ctx *Context) StopRecording() error {
+	recorder := ctx.recorder
+	if recorder == nil {
+		return nil
+	}
+	ctx.recorder = nil
+	err := recorder.writer.Flush()
+	if recorder.closer != nil {
+		if closeErr := recorder.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+func (ctx *Context) Recording() bool {
+	return ctx.recorder != nil
+}
+
+// StartReplay plays back a recording made with StartRecording. The input
+// state is restored to the one at the start of the recording, and every
+// following frame takes its input events and frame delta from the recording
+// instead of the platform, until the recording ends. The joysticks are the
+// recorded ones until then, and the window size stays live.
+func (ctx *Context) StartReplay(r io.Reader) error {
+	player := &inputPlayer{reader: bufio.NewReader(r)}
+	if closer, ok := r.(io.Closer); ok {
+		player.closer = closer
+	}
+	magic := make([]byte, len(inputRecordMagic))
+	if _, err := io.ReadFull(player.reader, magic); err != nil {
+		return err
+	}
+	if string(magic) != inputRecordMagic {
+		return errors.New("not an input recording")
+	}
+	version, err := binary.ReadUvarint(player.reader)
+	if err != nil {
+		return err
+	}
+	if version != inputRecordVersion {
+		return errors.New("unsupported input recording version")
+	}
+	live := ctx.input.joysticks
+	if ctx.player != nil {
+		live = ctx.player.live
+	}
+	if err := player.readSnapshot(ctx.input); err != nil {
+		return err
+	}
+	if ctx.player != nil && ctx.player.closer != nil {
+		ctx.player.closer.Close()
+	}
+	player.live = live
+	ctx.player = player
+	ctx.ignoreLiveInput()
+	return nil
+}
+
+// StartReplayFile is StartReplay from a file that is closed when the replay
+// ends.
+func (ctx *Context) StartReplayFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	if err := ctx.StartReplay(file); err != nil {
+		file.Close()
+		return err
+	}
+	return nil
+}
+
+func (ctx *Context) StopReplay() {
+	player := ctx.player
+	if player == nil {
+		return
+	}
+	ctx.player = nil
+	if player.closer != nil {
+		player.closer.Close()
+	}
+	ctx.input.replaceJoysticks(player.live)
+}
+
+func (ctx *Context) Replaying() bool {
+	return ctx.player != nil
+}
+
+// replayFrame replaces the queued input events of the frame with recorded
+// ones, keeping the platform events, and returns the recorded time and
+// delta. ok is false when the recording has ended.
+func (ctx *Context) replayFrame() (now, deltaTime float64, ok bool, err error) {
+	now, deltaTime, events, err := ctx.player.readFrame()
+	if err == io.EOF {
+		ctx.StopReplay()
+		return 0, 0, false, nil
+	}
+	if err != nil {
+		ctx.StopReplay()
+		return 0, 0, false, err
+	}
+	ctx.ignoreLiveInput()
+	for _, event := range events {
+		ctx.input.queue.push(event)
+	}
+	// the replay ends with its last frame, so that the next one is live
+	if _, err := ctx.player.reader.Peek(1); err == io.EOF {
+		ctx.player.ended = true
+	}
+	return now, deltaTime, true, nil
+}
+
+// ignoreLiveInput drops the queued input events but the platform ones, as
+// live input is ignored while replaying. The live joysticks are kept up to
+// date for when the replay ends.
+func (ctx *Context) ignoreLiveInput() {
+	live := ctx.player.live
+	for _, event := range ctx.input.queue.take() {
+		switch event.Type {
+		case INPUT_EVENT_JOYSTICK_CONNECT:
+			live[event.Device] = &joystickState{name: event.Text, gamepad: event.Gamepad}
+		case INPUT_EVENT_JOYSTICK_DISCONNECT:
+			delete(live, event.Device)
+		case INPUT_EVENT_JOYSTICK_AXIS:
+			if joystick := live[event.Device]; joystick != nil {
+				joystick.setAxis(event.Axis, event.X)
+			}
+		}
+		if platformEvent(event.Type) {
+			ctx.input.queue.push(event)
+		}
+	}
+}
+
+// platformEvent reports whether events of eventType come from the platform
+// rather than from the player, and are left out of recordings.
+func platformEvent(eventType int) bool {
+	return eventType == INPUT_EVENT_WINDOW_SIZE
+}
+
+func (r *inputRecorder) writeSnapshot(input *Input) {
+	r.writeFloat(input.currentTime)
+	r.writeFloat(input.curMouseX)
+	r.writeFloat(input.curMouseY)
+	r.writeBool(input.curMouseInside)
+
+	keys := []string{}
+	for key, state := range input.curKeyState {
+		if state == KEY_STATE_DOWN {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	r.writeUvarint(uint64(len(keys)))
+	for _, key := range keys {
+		r.writeString(key)
+	}
+
+	axes := []string{}
+	for axis := range input.axes {
+		axes = append(axes, axis)
+	}
+	sort.Strings(axes)
+	r.writeUvarint(uint64(len(axes)))
+	for _, axis := range axes {
+		r.writeString(axis)
+		r.writeUvarint(uint64(len(input.axes[axis])))
+		for _, meta := range input.axes[axis] {
+			r.writeFloat(input.axisValue[meta])
+		}
+	}
+
+	devices := input.Joysticks()
+	r.writeUvarint(uint64(len(devices)))
+	for _, device := range devices {
+		joystick := input.joysticks[device]
+		r.writeUvarint(uint64(device))
+		r.writeString(joystick.name)
+		r.writeBool(joystick.gamepad)
+		r.writeUvarint(uint64(len(joystick.axes)))
+		for _, value := range joystick.axes {
+			r.writeFloat(value)
+		}
+	}
+}
+
+func (r *inputRecorder) recordFrame(now, deltaTime float64, events []InputEvent) error {
+	r.writeFloat(now)
+	r.writeFloat(deltaTime)
+	recorded := make([]InputEvent, 0, len(events))
+	for _, event := range events {
+		if !platformEvent(event.Type) {
+			recorded = append(recorded, event)
+		}
+	}
+	r.writeUvarint(uint64(len(recorded)))
+	for _, event := range recorded {
+		r.writer.WriteByte(byte(event.Type))
+		switch event.Type {
+		case INPUT_EVENT_KEY, INPUT_EVENT_BUTTON:
+			r.writeString(event.Key)
+			r.writer.WriteByte(byte(event.State))
+		case INPUT_EVENT_MOUSE_MOVE, INPUT_EVENT_SCROLL:
+			r.writeFloat(event.X)
+			r.writeFloat(event.Y)
+		case INPUT_EVENT_TEXT:
+			r.writeString(event.Text)
+		case INPUT_EVENT_JOYSTICK_CONNECT:
+			r.writeUvarint(uint64(event.Device))
+			r.writeString(event.Text)
+			r.writeBool(event.Gamepad)
+		case INPUT_EVENT_JOYSTICK_DISCONNECT:
+			r.writeUvarint(uint64(event.Device))
+		case INPUT_EVENT_JOYSTICK_AXIS:
+			r.writeUvarint(uint64(event.Device))
+			r.writeUvarint(uint64(event.Axis))
+			r.writeFloat(event.X)
+		case INPUT_EVENT_JOYSTICK_BUTTON:
+			r.writeUvarint(uint64(event.Device))
+			r.writeUvarint(uint64(event.Axis))
+			r.writer.WriteByte(byte(event.State))
+		}
+	}
+	return r.writer.Flush()
+}
+
+func (r *inputRecorder) writeUvarint(v uint64) {
+	buf := make([]byte, binary.MaxVarintLen64)
+	r.writer.Write(buf[:binary.PutUvarint(buf, v)])
+}
+
+func (r *inputRecorder) writeFloat(v float64) {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, math.Float64bits(v))
+	r.writer.Write(buf)
+}
+
+func (r *inputRecorder) writeBool(v bool) {
+	if v {
+		r.writer.WriteByte(1)
+	} else {
+		r.writer.WriteByte(0)
+	}
+}
+
+func (r *inputRecorder) writeString(s string) {
+	r.writeUvarint(uint64(len(s)))
+	r.writer.WriteString(s)
+}
+
+func (p *inputPlayer) readSnapshot(input *Input) error {
+	var err error
+	var currentTime, mouseX, mouseY float64
+	var mouseInside bool
+	for _, v := range []*float64{&currentTime, &mouseX, &mouseY} {
+		if *v, err = p.readFloat(); err != nil {
+			return err
+		}
+	}
+	if mouseInside, err = p.readBool(); err != nil {
+		return err
+	}
+
+	count, err := binary.ReadUvarint(p.reader)
+	if err != nil {
+		return err
+	}
+	keyState := map[string]int{}
+	for i := uint64(0); i < count; i++ {
+		key, err := p.readString()
+		if err != nil {
+			return err
+		}
+		keyState[key] = KEY_STATE_DOWN
+	}
+
+	count, err = binary.ReadUvarint(p.reader)
+	if err != nil {
+		return err
+	}
+	axisValue := map[*AxisMeta]float64{}
+	for i := uint64(0); i < count; i++ {
+		axis, err := p.readString()
+		if err != nil {
+			return err
+		}
+		metaCount, err := binary.ReadUvarint(p.reader)
+		if err != nil {
+			return err
+		}
+		for j := uint64(0); j < metaCount; j++ {
+			value, err := p.readFloat()
+			if err != nil {
+				return err
+			}
+			if metas := input.axes[axis]; j < uint64(len(metas)) {
+				axisValue[metas[j]] = value
+			}
+		}
+	}
+
+	count, err = binary.ReadUvarint(p.reader)
+	if err != nil {
+		return err
+	}
+	joysticks := map[int]*joystickState{}
+	for i := uint64(0); i < count; i++ {
+		device, err := binary.ReadUvarint(p.reader)
+		if err != nil {
+			return err
+		}
+		joystick := &joystickState{}
+		if joystick.name, err = p.readString(); err != nil {
+			return err
+		}
+		if joystick.gamepad, err = p.readBool(); err != nil {
+			return err
+		}
+		axisCount, err := binary.ReadUvarint(p.reader)
+		if err != nil {
+			return err
+		}
+		for j := uint64(0); j < axisCount; j++ {
+			value, err := p.readFloat()
+			if err != nil {
+				return err
+			}
+			joystick.axes = append(joystick.axes, value)
+		}
+		joysticks[int(device)] = joystick
+	}
+
+	// the recorded devices stand in for the live ones, with nothing held
+	// on them as the key state is restored below
+	input.joysticks = joysticks
+	input.currentTime, input.lastTime = currentTime, currentTime
+	input.curMouseX, input.preMouseX = mouseX, mouseX
+	input.curMouseY, input.preMouseY = mouseY, mouseY
+	input.curMouseInside, input.preMouseInside = mouseInside, mouseInside
+	for key := range input.curKeyState {
+		input.curKeyState[key] = KEY_STATE_UP
+	}
+	for key := range input.preKeyState {
+		input.preKeyState[key] = KEY_STATE_UP
+	}
+	for key, state := range keyState {
+		input.curKeyState[key] = state
+		input.preKeyState[key] = state
+	}
+	for meta := range input.axisValue {
+		input.axisValue[meta] = axisValue[meta]
+	}
+	return nil
+}
+
+func (p *inputPlayer) readFrame() (now, deltaTime float64, events []InputEvent, err error) {
+	if now, err = p.readFloat(); err != nil {
+		return
+	}
+	if deltaTime, err = p.readFloat(); err != nil {
+		return 0, 0, nil, unexpectedEOF(err)
+	}
+	count, err := binary.ReadUvarint(p.reader)
+	if err != nil {
+		return 0, 0, nil, unexpectedEOF(err)
+	}
+	for i := uint64(0); i < count; i++ {
+		event := InputEvent{}
+		eventType, err := p.reader.ReadByte()
+		if err != nil {
+			return 0, 0, nil, unexpectedEOF(err)
+		}
+		event.Type = int(eventType)
+		switch event.Type {
+		case INPUT_EVENT_KEY, INPUT_EVENT_BUTTON:
+			if event.Key, err = p.readString(); err != nil {
+				return 0, 0, nil, unexpectedEOF(err)
+			}
+			state, err := p.reader.ReadByte()
+			if err != nil {
+				return 0, 0, nil, unexpectedEOF(err)
+			}
+			event.State = int(state)
+		case INPUT_EVENT_MOUSE_MOVE, INPUT_EVENT_SCROLL:
+			if event.X, err = p.readFloat(); err != nil {
+				return 0, 0, nil, unexpectedEOF(err)
+			}
+			if event.Y, err = p.readFloat(); err != nil {
+				return 0, 0, nil, unexpectedEOF(err)
+			}
+		case INPUT_EVENT_TEXT:
+			if event.Text, err = p.readString(); err != nil {
+				return 0, 0, nil, unexpectedEOF(err)
+			}
+		case INPUT_EVENT_MOUSE_ENTER, INPUT_EVENT_MOUSE_LEAVE:
+		case INPUT_EVENT_JOYSTICK_CONNECT, INPUT_EVENT_JOYSTICK_DISCONNECT,
+			INPUT_EVENT_JOYSTICK_AXIS, INPUT_EVENT_JOYSTICK_BUTTON:
+			if err := p.readJoystickEvent(&event); err != nil {
+				return 0, 0, nil, unexpectedEOF(err)
+			}
+		default:
+			return 0, 0, nil, errors.New("unknown input event type in recording")
+		}
+		events = append(events, event)
+	}
+	return now, deltaTime, events, nil
+}
+
+func (p *inputPlayer) readJoystickEvent(event *InputEvent) error {
+	device, err := binary.ReadUvarint(p.reader)
+	if err != nil {
+		return err
+	}
+	event.Device = int(device)
+	switch event.Type {
+	case INPUT_EVENT_JOYSTICK_CONNECT:
+		if event.Text, err = p.readString(); err != nil {
+			return err
+		}
+		event.Gamepad, err = p.readBool()
+		return err
+	case INPUT_EVENT_JOYSTICK_DISCONNECT:
+		return nil
+	}
+	axis, err := binary.ReadUvarint(p.reader)
+	if err != nil {
+		return err
+	}
+	event.Axis = int(axis)
+	if event.Type == INPUT_EVENT_JOYSTICK_AXIS {
+		event.X, err = p.readFloat()
+		return err
+	}
+	state, err := p.reader.ReadByte()
+	event.State = int(state)
+	return err
+}
+
+func (p *inputPlayer) readBool() (bool, error) {
+	b, err := p.reader.ReadByte()
+	return b != 0, err
+}
+
+func (p *inputPlayer) readFloat() (float64, error) {
+	buf := make([]byte, 8)
+	if _, err := io.ReadFull(p.reader, buf); err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(buf)), nil
+}
+
+func (p *inputPlayer) readString() (string, error) {
+	length, err := binary.ReadUvarint(p.reader)
+	if err != nil {
+		return "", err
+	}
+	if length > inputRecordMaxString {
+		return "", errors.New("string too long in input recording")
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(p.reader, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+// unexpectedEOF turns an EOF in the middle of a frame into an error, so that
+// only a recording cut between frames ends normally.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,305 @@
+package wengine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// eventLogBehavior logs the input events and the delta of every frame.
+type eventLogBehavior struct {
+	events [][]InputEvent
+	deltas []float64
+}
+
+func (b *eventLogBehavior) Start(bctx *BehaviorContext) {}
+func (b *eventLogBehavior) Update(bctx *BehaviorContext) {
+	b.events = append(b.events, append([]InputEvent(nil), bctx.Context.Input().Events()...))
+	b.deltas = append(b.deltas, bctx.UnscaledDeltaTime)
+}
+
+func newEventLogContext(t *testing.T) (*Context, *eventLogBehavior) {
+	ctx := NewHeadlessContext()
+	log := &eventLogBehavior{}
+	scene := NewScene()
+	object := NewObject()
+	object.SetEnabled(true)
+	object.AddBehavior(log)
+	scene.RegisterObject("log", object)
+	ctx.RegisterScene("scene", scene)
+	ctx.ApplyScene("scene")
+	if err := ctx.Step(1.0 / 60); err != nil {
+		t.Fatal(err)
+	}
+	log.events, log.deltas = nil, nil
+	return ctx, log
+}
+
+func TestReplayRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		frames [][]InputEvent
+	}{
+		{"no events", [][]InputEvent{nil, nil}},
+		{"keys and buttons", [][]InputEvent{
+			{{Type: INPUT_EVENT_KEY, Key: "a", State: KEY_STATE_DOWN}},
+			{{Type: INPUT_EVENT_BUTTON, Key: "mouse left", State: KEY_STATE_DOWN}, {Type: INPUT_EVENT_KEY, Key: "a", State: KEY_STATE_UP}},
+		}},
+		{"mouse", [][]InputEvent{
+			{{Type: INPUT_EVENT_MOUSE_ENTER}, {Type: INPUT_EVENT_MOUSE_MOVE, X: 10, Y: 20}},
+			{{Type: INPUT_EVENT_SCROLL, X: 0, Y: -1.5}, {Type: INPUT_EVENT_MOUSE_LEAVE}},
+		}},
+		{"text", [][]InputEvent{{{Type: INPUT_EVENT_TEXT, Text: "héllo"}}}},
+		{"joystick", [][]InputEvent{
+			{{Type: INPUT_EVENT_JOYSTICK_CONNECT, Device: 1, Text: "pad", Gamepad: true}},
+			{{Type: INPUT_EVENT_JOYSTICK_AXIS, Device: 1, Axis: AXIS_FROM_LEFT_X, X: 0.5}},
+			{{Type: INPUT_EVENT_JOYSTICK_BUTTON, Device: 1, Axis: 2, State: KEY_STATE_DOWN}},
+			{{Type: INPUT_EVENT_JOYSTICK_DISCONNECT, Device: 1}},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, log := newEventLogContext(t)
+			buf := &bytes.Buffer{}
+			if err := ctx.StartRecording(buf); err != nil {
+				t.Fatal(err)
+			}
+			for i, events := range test.frames {
+				for _, event := range events {
+					ctx.Input().Inject(event)
+				}
+				if err := ctx.Step(float64(i+1) / 100); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := ctx.StopRecording(); err != nil {
+				t.Fatal(err)
+			}
+
+			replayCtx, replayLog := newEventLogContext(t)
+			if err := replayCtx.StartReplay(bytes.NewReader(buf.Bytes())); err != nil {
+				t.Fatal(err)
+			}
+			for range test.frames {
+				replayCtx.Input().InjectKey("live", true)
+				if err := replayCtx.Step(1); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if replayCtx.Replaying() {
+				t.Error("still replaying after the last frame")
+			}
+			if !reflect.DeepEqual(replayLog.deltas, log.deltas) {
+				t.Errorf("deltas = %v, want %v", replayLog.deltas, log.deltas)
+			}
+			for i := range log.events {
+				if len(log.events[i]) == 0 && len(replayLog.events[i]) == 0 {
+					continue
+				}
+				if !reflect.DeepEqual(replayLog.events[i], log.events[i]) {
+					t.Errorf("frame %d events = %v, want %v", i, replayLog.events[i], log.events[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReplayKeepsPlatformEvents(t *testing.T) {
+	ctx, _ := newEventLogContext(t)
+	buf := &bytes.Buffer{}
+	ctx.StartRecording(buf)
+	ctx.Input().InjectWindowSize(800, 600)
+	ctx.Input().InjectKey("a", true)
+	ctx.Step(1.0 / 60)
+	ctx.StopRecording()
+
+	replayCtx, replayLog := newEventLogContext(t)
+	if err := replayCtx.StartReplay(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	replayCtx.Input().InjectWindowSize(1024, 768)
+	replayCtx.Input().InjectJoystickConnect(3, "pad", true)
+	replayCtx.Step(1.0 / 60)
+	want := []InputEvent{
+		{Type: INPUT_EVENT_WINDOW_SIZE, X: 1024, Y: 768},
+		{Type: INPUT_EVENT_KEY, Key: "a", State: KEY_STATE_DOWN},
+	}
+	if !reflect.DeepEqual(replayLog.events[0], want) {
+		t.Errorf("events = %v, want %v", replayLog.events[0], want)
+	}
+	// the live joystick connected while replaying is there once it ends
+	if devices := replayCtx.Input().Joysticks(); len(devices) != 1 || devices[0] != 3 {
+		t.Errorf("joysticks = %v, want [3]", devices)
+	}
+}
+
+func TestReplayVirtualJoysticks(t *testing.T) {
+	ctx, _ := newEventLogContext(t)
+	ctx.Input().InjectJoystickConnect(2, "recorded pad", true)
+	ctx.Input().InjectJoystickAxis(2, AXIS_FROM_LEFT_Y, -0.5)
+	ctx.Step(1.0 / 60)
+	buf := &bytes.Buffer{}
+	ctx.StartRecording(buf)
+	ctx.Input().InjectJoystickButton(2, GAMEPAD_BUTTON_A, true)
+	ctx.Input().InjectJoystickConnect(4, "recorded stick", false)
+	ctx.Step(1.0 / 60)
+	ctx.Input().InjectJoystickAxis(4, AXIS_FROM_LEFT_X, 1)
+	ctx.Input().InjectJoystickDisconnect(2)
+	ctx.Step(1.0 / 60)
+	ctx.Step(1.0 / 60)
+	ctx.StopRecording()
+
+	// played back without the recorded devices, and another live one
+	replayCtx, _ := newEventLogContext(t)
+	replayCtx.Input().InjectJoystickConnect(1, "live pad", true)
+	replayCtx.Input().InjectJoystickAxis(1, AXIS_FROM_LEFT_X, 0.25)
+	replayCtx.Step(1.0 / 60)
+	if err := replayCtx.StartReplay(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	input := replayCtx.Input()
+	if devices := input.Joysticks(); !reflect.DeepEqual(devices, []int{2}) {
+		t.Fatalf("joysticks at the start = %v, want [2]", devices)
+	}
+	if input.JoystickName(2) != "recorded pad" || !input.IsGamepad(2) || input.GetJoystickAxis(2, AXIS_FROM_LEFT_Y) != -0.5 {
+		t.Errorf("device 2 = %q, gamepad %v, axis %v", input.JoystickName(2), input.IsGamepad(2), input.GetJoystickAxis(2, AXIS_FROM_LEFT_Y))
+	}
+
+	input.InjectJoystickButton(1, GAMEPAD_BUTTON_B, true)
+	replayCtx.Step(1)
+	if devices := input.Joysticks(); !reflect.DeepEqual(devices, []int{2, 4}) {
+		t.Errorf("joysticks = %v, want [2 4]", devices)
+	}
+	if !input.GetKey("gamepad 2 a") || input.IsGamepad(4) {
+		t.Error("recorded button or device not replayed")
+	}
+	if input.GetKey("gamepad 1 b") {
+		t.Error("live button applied while replaying")
+	}
+
+	input.InjectJoystickDisconnect(1)
+	input.InjectJoystickConnect(5, "late pad", false)
+	replayCtx.Step(1)
+	if devices := input.Joysticks(); !reflect.DeepEqual(devices, []int{4}) {
+		t.Errorf("joysticks = %v, want [4]", devices)
+	}
+	if input.GetJoystickAxis(4, AXIS_FROM_LEFT_X) != 1 || input.GetKey("gamepad 2 a") {
+		t.Error("recorded axis or release not replayed")
+	}
+
+	// the live devices are back once the replay ended
+	replayCtx.Step(1)
+	if replayCtx.Replaying() {
+		t.Fatal("still replaying after the last frame")
+	}
+	if devices := input.Joysticks(); !reflect.DeepEqual(devices, []int{5}) {
+		t.Errorf("joysticks after the replay = %v, want [5]", devices)
+	}
+	replayCtx.Step(1)
+	if input.JoystickName(5) != "late pad" || input.GetKey("joystick 4 button 0") {
+		t.Error("live devices not restored")
+	}
+}
+
+func TestReplayRecordedGamepadFlag(t *testing.T) {
+	tests := []struct {
+		name            string
+		recordedGamepad bool
+		liveGamepad     bool
+		wantKey         string
+	}{
+		{"gamepad over a joystick", true, false, "gamepad 1 a"},
+		{"joystick over a gamepad", false, true, "joystick 1 button 0"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, _ := newEventLogContext(t)
+			ctx.Input().InjectJoystickConnect(1, "recorded", test.recordedGamepad)
+			ctx.Input().InjectJoystickAxis(1, AXIS_FROM_RIGHT_TRIGGER, 0.75)
+			ctx.Step(1.0 / 60)
+			buf := &bytes.Buffer{}
+			ctx.StartRecording(buf)
+			ctx.Input().InjectJoystickButton(1, 0, true)
+			ctx.Step(1.0 / 60)
+			ctx.Step(1.0 / 60)
+			ctx.StopRecording()
+
+			replayCtx, _ := newEventLogContext(t)
+			input := replayCtx.Input()
+			input.InjectJoystickConnect(1, "live", test.liveGamepad)
+			input.InjectJoystickAxis(1, AXIS_FROM_RIGHT_TRIGGER, 0.25)
+			replayCtx.Step(1.0 / 60)
+			if err := replayCtx.StartReplay(bytes.NewReader(buf.Bytes())); err != nil {
+				t.Fatal(err)
+			}
+			if input.IsGamepad(1) != test.recordedGamepad {
+				t.Errorf("gamepad = %v, want the recorded %v", input.IsGamepad(1), test.recordedGamepad)
+			}
+			if got := input.JoystickButtonKey(1, 0); got != test.wantKey {
+				t.Errorf("button key = %q, want %q", got, test.wantKey)
+			}
+			if got := input.GetJoystickAxis(1, AXIS_FROM_RIGHT_TRIGGER); got != 0.75 {
+				t.Errorf("axis = %v, want the recorded 0.75", got)
+			}
+			replayCtx.Step(1)
+			if !input.GetKey(test.wantKey) {
+				t.Errorf("%q not held", test.wantKey)
+			}
+		})
+	}
+}
+
+func TestStartReplayErrors(t *testing.T) {
+	uvarint := func(v uint64) []byte {
+		buf := make([]byte, binary.MaxVarintLen64)
+		return buf[:binary.PutUvarint(buf, v)]
+	}
+	snapshotStart := append(append([]byte(inputRecordMagic), uvarint(inputRecordVersion)...), make([]byte, 8*3+1)...)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"bad magic", []byte("WAVE\x01")},
+		{"version 0", append([]byte(inputRecordMagic), uvarint(0)...)},
+		{"future version", append([]byte(inputRecordMagic), uvarint(inputRecordVersion+1)...)},
+		{"truncated snapshot", snapshotStart[:len(snapshotStart)-1]},
+		{"huge key name", append(append(append([]byte{}, snapshotStart...), uvarint(1)...), uvarint(1<<40)...)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := NewHeadlessContext()
+			if err := ctx.StartReplay(bytes.NewReader(test.data)); err == nil {
+				t.Error("no error")
+			}
+			if ctx.Replaying() {
+				t.Error("replaying after an error")
+			}
+		})
+	}
+}
+
+func TestReplayEventsOnly(t *testing.T) {
+	ctx, log := newEventLogContext(t)
+	buf := &bytes.Buffer{}
+	ctx.StartRecording(buf)
+	ctx.Input().InjectKey("a", true)
+	ctx.Step(0.5)
+	ctx.Step(0.25)
+	ctx.StopRecording()
+
+	replayCtx, replayLog := newEventLogContext(t)
+	if err := replayCtx.StartReplay(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	replayCtx.player.eventsOnly = true
+	replayCtx.Step(0.1)
+	replayCtx.Step(0.1)
+	if !reflect.DeepEqual(replayLog.deltas, []float64{0.1, 0.1}) {
+		t.Errorf("deltas = %v, want the stepped ones", replayLog.deltas)
+	}
+	if !reflect.DeepEqual(replayLog.events, log.events) {
+		t.Errorf("events = %v, want %v", replayLog.events, log.events)
+	}
+}
@@ -1,11 +1,22 @@
 package wengine
 
-import "github.com/go-gl/mathgl/mgl32"
+import (
+	"sort"
+	"strconv"
+)
 
 type ObjectMap map[string]*Object
 
 type Scene struct {
 	objects ObjectMap
+
+	toDestroy []*Object
+	// toLoad lists the objects instantiated since the assets of the scene
+	// were last loaded
+	toLoad []*Object
+
+	// context is the context the scene was last applied in
+	context *Context
 }
 
 func NewScene() *Scene {
@@ -17,30 +28,113 @@ func (s *Scene) Objects() ObjectMap {
 }
 
 func (s *Scene) RegisterObject(name string, object *Object) {
+	object.name = name
+	object.scene = s
 	s.objects[name] = object
 }
 
-func (s *Scene) updateTransforms() {
-	for _, obj := range s.Objects() {
-		if !obj.enabled {
+// notifyActive tells the behaviors of object that its active state changed,
+// if the scene is the current one of its context. Otherwise they are told
+// before their first update.
+func (s *Scene) notifyActive(object *Object) {
+	ctx := s.context
+	if ctx == nil || ctx.currentScene != s {
+		return
+	}
+	for _, slot := range object.behaviors {
+		ctx.notifyActive(object, slot)
+	}
+}
+
+// Instantiate registers object and all of its unregistered descendants in the
+// scene while it may be running. If name is taken, a unique one is derived
+// from it. Behaviors of the new objects are started before their first
+// update, and the assets of their meshes are loaded before the next frame
+// is rendered. The name the object was registered under is returned.
+func (s *Scene) Instantiate(name string, object *Object) string {
+	name = s.uniqueName(name)
+	s.RegisterObject(name, object)
+	s.toLoad = append(s.toLoad, object)
+	for i, child := range object.children {
+		if s.objects[child.name] == child {
 			continue
 		}
-		model := s.buildTransform(obj)
-		obj.model = model
-		obj.position = model.Mul4x1(mgl32.Vec4{0, 0, 0, 1}).Vec3()
-		obj.up = model.Mul4x1(mgl32.Vec4{0, 1, 0, 0}).Vec3()
-		obj.right = model.Mul4x1(mgl32.Vec4{1, 0, 0, 0}).Vec3()
-		obj.forward = obj.up.Cross(obj.right)
+		s.Instantiate(name+"/"+strconv.Itoa(i), child)
+	}
+	return name
+}
+
+func (s *Scene) uniqueName(name string) string {
+	if _, exists := s.objects[name]; !exists {
+		return name
+	}
+	for i := 1; ; i++ {
+		candidate := name + " (" + strconv.Itoa(i) + ")"
+		if _, exists := s.objects[candidate]; !exists {
+			return candidate
+		}
+	}
+}
+
+// Destroy marks object and its descendants for destruction. They are removed
+// from the scene at the end of the current frame, after OnDestroy of their
+// behaviors is called.
+func (s *Scene) Destroy(object *Object) {
+	if object.destroyed {
+		return
+	}
+	object.Walk(func(obj *Object) bool {
+		obj.destroyed = true
+		return true
+	})
+	s.toDestroy = append(s.toDestroy, object)
+}
+
+// takeDestroyed removes objects marked by Destroy from the scene, returning
+// them parents first, whatever order they were destroyed in.
+func (s *Scene) takeDestroyed() []*Object {
+	destroyed := []*Object{}
+	depths := map[*Object]int{}
+	for _, object := range s.toDestroy {
+		object.Walk(func(obj *Object) bool {
+			if _, seen := depths[obj]; seen {
+				return false
+			}
+			depth := 0
+			for p := obj.parent; p != nil; p = p.parent {
+				depth++
+			}
+			depths[obj] = depth
+			destroyed = append(destroyed, obj)
+			return true
+		})
+	}
+	sort.SliceStable(destroyed, func(i, j int) bool {
+		return depths[destroyed[i]] < depths[destroyed[j]]
+	})
+
+	for _, obj := range destroyed {
+		if s.objects[obj.name] == obj {
+			delete(s.objects, obj.name)
+		}
+		obj.scene = nil
+	}
+	for _, object := range s.toDestroy {
+		if object.parent != nil {
+			object.parent.removeChild(object)
+			object.parent = nil
+			object.updateActive()
+		}
 	}
+	s.toDestroy = nil
+	return destroyed
 }
 
-func (s *Scene) buildTransform(object *Object) mgl32.Mat4 {
-	model := mgl32.Ident4()
-	for t := object; t != nil; t = t.parent {
-		model = t.TranslationMatrix().
-			Mul4(t.RotationMatrix()).
-			Mul4(t.ScaleMatrix()).
-			Mul4(model)
+func (s *Scene) updateTransforms() {
+	for _, obj := range s.Objects() {
+		if !obj.ActiveInHierarchy() {
+			continue
+		}
+		obj.updateTransform()
 	}
-	return model
 }
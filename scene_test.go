@@ -0,0 +1,112 @@
+package wengine
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDestroyOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		destroy func(scene *Scene, parent, child *Object)
+		want    []string
+	}{
+		{"parent", func(scene *Scene, parent, child *Object) {
+			scene.Destroy(parent)
+		}, []string{"parent.OnDestroy", "child.OnDestroy"}},
+		{"child", func(scene *Scene, parent, child *Object) {
+			scene.Destroy(child)
+		}, []string{"child.OnDestroy"}},
+		{"child then parent", func(scene *Scene, parent, child *Object) {
+			scene.Destroy(child)
+			scene.Destroy(parent)
+		}, []string{"parent.OnDestroy", "child.OnDestroy"}},
+		{"parent then child", func(scene *Scene, parent, child *Object) {
+			scene.Destroy(parent)
+			scene.Destroy(child)
+		}, []string{"parent.OnDestroy", "child.OnDestroy"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, parent, child, log := newLogScene(t)
+			scene := ctx.CurrentScene()
+			test.destroy(scene, parent, child)
+			ctx.destroyObjects()
+			if !reflect.DeepEqual(*log, test.want) {
+				t.Errorf("calls = %v, want %v", *log, test.want)
+			}
+			for _, obj := range []*Object{parent, child} {
+				if obj.Destroyed() && scene.Objects()[obj.Name()] == obj {
+					t.Errorf("%s still in the scene", obj.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestInstantiateUniqueNames(t *testing.T) {
+	scene := NewScene()
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"enemy", "enemy"},
+		{"enemy", "enemy (1)"},
+		{"enemy", "enemy (2)"},
+		{"boss", "boss"},
+	}
+	for _, test := range tests {
+		if got := scene.Instantiate(test.name, NewObject()); got != test.want {
+			t.Errorf("Instantiate(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestInstantiateLoadsAssets(t *testing.T) {
+	ctx, _, _ := newNullScene(t)
+	ctx.RegisterAsset("plane", DefaultPlaneMeshAsset())
+	ctx.RegisterAsset("sprite", DefaultSpriteMeshAsset())
+	ctx.RegisterAsset("destroyed", DefaultSpriteMeshAsset())
+	if err := ctx.Step(1.0 / 60); err != nil {
+		t.Fatal(err)
+	}
+	renderer := ctx.Renderer().(*NullRenderer)
+	scene := ctx.CurrentScene()
+
+	parent, child := NewObject(), NewObject()
+	parent.SetEnabled(true)
+	parent.AttachComponent(&MeshComponent{Mesh: "plane"})
+	child.SetEnabled(true)
+	child.AttachComponent(&MeshComponent{Mesh: "sprite"})
+	child.SetParent(parent, false)
+	scene.Instantiate("spawned", parent)
+	destroyed := NewObject()
+	destroyed.AttachComponent(&MeshComponent{Mesh: "destroyed"})
+	scene.Instantiate("destroyed", destroyed)
+	scene.Destroy(destroyed)
+	if err := ctx.Step(1.0 / 60); err != nil {
+		t.Fatal(err)
+	}
+
+	if installed := renderer.Installed(); !reflect.DeepEqual(installed, []string{"cube", "plane", "sprite"}) {
+		t.Errorf("installed = %v", installed)
+	}
+	for _, name := range []string{"plane", "sprite"} {
+		if !ctx.Assets()[name].Loaded() {
+			t.Errorf("%s not loaded", name)
+		}
+	}
+	if ctx.Assets()["destroyed"].Loaded() {
+		t.Error("asset of a destroyed object loaded")
+	}
+	if meshes := renderer.LastFrame().Meshes; len(meshes) != 3 {
+		t.Errorf("%d meshes drawn, want 3", len(meshes))
+	}
+
+	if err := ctx.Step(1.0 / 60); err != nil {
+		t.Fatal(err)
+	}
+	if installed := renderer.Installed(); len(installed) != 3 {
+		t.Errorf("installed again: %v", installed)
+	}
+}
@@ -0,0 +1,234 @@
+package wengine
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-gl/mathgl/mgl32"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+)
+
+// SCENE_FILE_VERSION is written into every saved scene. Files with a newer
+// version are rejected on load.
+const SCENE_FILE_VERSION = 1
+
+var (
+	registeredComponentTypes = map[string]func() Component{}
+	registeredBehaviorTypes  = map[string]func() Behavior{}
+	componentTypeNames       = map[reflect.Type]string{}
+	behaviorTypeNames        = map[reflect.Type]string{}
+)
+
+func init() {
+	RegisterComponentType("camera", func() Component { return &CameraComponent{} })
+	RegisterComponentType("light", func() Component { return &LightComponent{} })
+	RegisterComponentType("mesh", func() Component { return &MeshComponent{} })
+	RegisterComponentType("sprite", func() Component { return &SpriteComponent{} })
+}
+
+// RegisterComponentType makes a component type available to scene files
+// under name. Exported fields of the component are saved and loaded with
+// encoding/json rules, so json tags and json.Marshaler can be used to
+// control what is serialized.
+func RegisterComponentType(name string, factory func() Component) {
+	registeredComponentTypes[name] = factory
+	componentTypeNames[reflect.TypeOf(factory())] = name
+}
+
+// RegisterBehaviorType makes a behavior type available to scene files under
+// name, with the same serialization rules as RegisterComponentType.
+func RegisterBehaviorType(name string, factory func() Behavior) {
+	registeredBehaviorTypes[name] = factory
+	behaviorTypeNames[reflect.TypeOf(factory())] = name
+}
+
+type sceneFile struct {
+	Version int          `json:"version"`
+	Objects []objectFile `json:"objects"`
+}
+
+type objectFile struct {
+	Name     string     `json:"name"`
+	Parent   string     `json:"parent,omitempty"`
+	Enabled  bool       `json:"enabled"`
+	Position mgl32.Vec3 `json:"position"`
+	// w, x, y, z
+	Rotation   [4]float32   `json:"rotation"`
+	Scale      mgl32.Vec3   `json:"scale"`
+	Components []typedValue `json:"components,omitempty"`
+	Behaviors  []typedValue `json:"behaviors,omitempty"`
+}
+
+// UnmarshalJSON defaults a missing scale to 1, a missing rotation to the
+// identity and a missing enabled to true, as in hand-written files.
+func (of *objectFile) UnmarshalJSON(data []byte) error {
+	type plainObjectFile objectFile
+	plain := plainObjectFile{Enabled: true, Rotation: [4]float32{1, 0, 0, 0}, Scale: mgl32.Vec3{1, 1, 1}}
+	if err := json.Unmarshal(data, &plain); err != nil {
+		return err
+	}
+	*of = objectFile(plain)
+	return nil
+}
+
+type typedValue struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+func ReadScene(r io.Reader) (*Scene, error) {
+	file := sceneFile{}
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, err
+	}
+	if file.Version < 1 || file.Version > SCENE_FILE_VERSION {
+		return nil, errors.New("unsupported scene file version")
+	}
+
+	scene := NewScene()
+	for _, of := range file.Objects {
+		if _, exists := scene.objects[of.Name]; exists {
+			return nil, errors.New("duplicated object name: " + of.Name)
+		}
+		obj := NewObject()
+		obj.SetEnabled(of.Enabled)
+		obj.localPosition = of.Position
+		obj.localRotation = mgl32.Quat{W: of.Rotation[0], V: mgl32.Vec3{of.Rotation[1], of.Rotation[2], of.Rotation[3]}}.Normalize()
+		obj.localScale = of.Scale
+		obj.markLocalDirty()
+
+		for _, cf := range of.Components {
+			factory, exists := registeredComponentTypes[cf.Type]
+			if !exists {
+				return nil, errors.New("unknown component type: " + cf.Type)
+			}
+			compo := factory()
+			if err := json.Unmarshal(cf.Data, compo); err != nil {
+				return nil, err
+			}
+			obj.AttachComponent(compo)
+		}
+
+		for _, bf := range of.Behaviors {
+			factory, exists := registeredBehaviorTypes[bf.Type]
+			if !exists {
+				return nil, errors.New("unknown behavior type: " + bf.Type)
+			}
+			behavior := factory()
+			if err := json.Unmarshal(bf.Data, behavior); err != nil {
+				return nil, err
+			}
+			obj.AddBehavior(behavior)
+		}
+
+		scene.RegisterObject(of.Name, obj)
+	}
+
+	for _, of := range file.Objects {
+		if of.Parent == "" {
+			continue
+		}
+		parent, exists := scene.objects[of.Parent]
+		if !exists {
+			return nil, errors.New("no such parent object: " + of.Parent)
+		}
+		if err := scene.objects[of.Name].SetParent(parent, false); err != nil {
+			return nil, err
+		}
+	}
+
+	return scene, nil
+}
+
+func WriteScene(w io.Writer, scene *Scene) error {
+	names := map[*Object]string{}
+	for name, obj := range scene.objects {
+		names[obj] = name
+	}
+
+	file := sceneFile{Version: SCENE_FILE_VERSION, Objects: []objectFile{}}
+	for name, obj := range scene.objects {
+		of := objectFile{
+			Name:     name,
+			Enabled:  obj.enabled,
+			Position: obj.localPosition,
+			Rotation: [4]float32{obj.localRotation.W, obj.localRotation.V[0], obj.localRotation.V[1], obj.localRotation.V[2]},
+			Scale:    obj.localScale,
+		}
+
+		if obj.parent != nil {
+			parentName, exists := names[obj.parent]
+			if !exists {
+				return errors.New("parent of " + name + " is not in the scene")
+			}
+			of.Parent = parentName
+		}
+
+		for _, compo := range obj.attached {
+			typeName, exists := componentTypeNames[reflect.TypeOf(compo)]
+			if !exists {
+				return errors.New("unregistered component type in " + name)
+			}
+			data, err := json.Marshal(compo)
+			if err != nil {
+				return err
+			}
+			of.Components = append(of.Components, typedValue{Type: typeName, Data: data})
+		}
+
+		for _, slot := range obj.behaviors {
+			typeName, exists := behaviorTypeNames[reflect.TypeOf(slot.behavior)]
+			if !exists {
+				return errors.New("unregistered behavior type in " + name)
+			}
+			data, err := json.Marshal(slot.behavior)
+			if err != nil {
+				return err
+			}
+			of.Behaviors = append(of.Behaviors, typedValue{Type: typeName, Data: data})
+		}
+
+		file.Objects = append(file.Objects, of)
+	}
+	sort.Slice(file.Objects, func(i, j int) bool {
+		return file.Objects[i].Name < file.Objects[j].Name
+	})
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(&file)
+}
+
+// LoadSceneFile reads a scene file and registers it under name.
+func (ctx *Context) LoadSceneFile(name string, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scene, err := ReadScene(file)
+	if err != nil {
+		return err
+	}
+	ctx.RegisterScene(name, scene)
+	return nil
+}
+
+// SaveScene writes the scene registered under name to path.
+func (ctx *Context) SaveScene(name string, path string) error {
+	scene, exists := ctx.scenes[name]
+	if !exists {
+		return errors.New("no such scene")
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WriteScene(file, scene); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
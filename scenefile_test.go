@@ -0,0 +1,143 @@
+package wengine
+
+import (
+	"bytes"
+	"github.com/go-gl/mathgl/mgl32"
+	"strings"
+	"testing"
+)
+
+type sceneFileBehavior struct {
+	Speed float32 `json:"speed"`
+}
+
+func (b *sceneFileBehavior) Start(bctx *BehaviorContext)  {}
+func (b *sceneFileBehavior) Update(bctx *BehaviorContext) {}
+
+func init() {
+	RegisterBehaviorType("test.sceneFileBehavior", func() Behavior { return &sceneFileBehavior{} })
+}
+
+func TestReadScene(t *testing.T) {
+	tests := []struct {
+		name          string
+		file          string
+		wantErr       bool
+		wantScale     mgl32.Vec3
+		wantDisabled  bool
+		wantBehaviors []float32
+	}{
+		{
+			name:          "behaviors",
+			file:          `{"version": 1, "objects": [{"name": "a", "scale": [1, 2, 3], "behaviors": [{"type": "test.sceneFileBehavior", "data": {"speed": 1}}, {"type": "test.sceneFileBehavior", "data": {"speed": 2}}]}]}`,
+			wantScale:     mgl32.Vec3{1, 2, 3},
+			wantBehaviors: []float32{1, 2},
+		},
+		{
+			name:      "missing scale",
+			file:      `{"version": 1, "objects": [{"name": "a", "position": [1, 0, 0]}]}`,
+			wantScale: mgl32.Vec3{1, 1, 1},
+		},
+		{
+			name:         "disabled",
+			file:         `{"version": 1, "objects": [{"name": "a", "enabled": false}]}`,
+			wantScale:    mgl32.Vec3{1, 1, 1},
+			wantDisabled: true,
+		},
+		{name: "version 0", file: `{"version": 0, "objects": []}`, wantErr: true},
+		{name: "future version", file: `{"version": 2, "objects": []}`, wantErr: true},
+		{name: "unknown component", file: `{"version": 1, "objects": [{"name": "a", "components": [{"type": "nope", "data": {}}]}]}`, wantErr: true},
+		{name: "unknown behavior", file: `{"version": 1, "objects": [{"name": "a", "behaviors": [{"type": "nope", "data": {}}]}]}`, wantErr: true},
+		{name: "missing parent", file: `{"version": 1, "objects": [{"name": "a", "parent": "b"}]}`, wantErr: true},
+		{name: "duplicated name", file: `{"version": 1, "objects": [{"name": "a"}, {"name": "a"}]}`, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scene, err := ReadScene(strings.NewReader(test.file))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err = %v, want error %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			obj := scene.Objects()["a"]
+			if obj.LocalScale() != test.wantScale {
+				t.Errorf("scale = %v, want %v", obj.LocalScale(), test.wantScale)
+			}
+			if obj.Enabled() == test.wantDisabled {
+				t.Errorf("enabled = %v, want %v", obj.Enabled(), !test.wantDisabled)
+			}
+			if obj.LocalRotation() != mgl32.QuatIdent() {
+				t.Errorf("rotation = %v, want identity", obj.LocalRotation())
+			}
+			behaviors := obj.Behaviors()
+			if len(behaviors) != len(test.wantBehaviors) {
+				t.Fatalf("%d behaviors, want %d", len(behaviors), len(test.wantBehaviors))
+			}
+			for i, behavior := range behaviors {
+				if speed := behavior.(*sceneFileBehavior).Speed; speed != test.wantBehaviors[i] {
+					t.Errorf("behavior %d speed = %v, want %v", i, speed, test.wantBehaviors[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWriteSceneRoundTrip(t *testing.T) {
+	scene := NewScene()
+	parent, child := NewObject(), NewObject()
+	parent.SetEnabled(true)
+	parent.SetLocalPosition(mgl32.Vec3{1, 2, 3})
+	parent.SetLocalScale(mgl32.Vec3{2, 1, 1})
+	parent.AttachComponent(&MeshComponent{Mesh: "first"})
+	parent.AttachComponent(&LightComponent{LightSource: LIGHT_SOURCE_POINT, Range: 5})
+	parent.AttachComponent(&MeshComponent{Mesh: "second"})
+	child.Rotate(mgl32.DegToRad(30), mgl32.Vec3{0, 1, 0})
+	child.AddBehavior(&sceneFileBehavior{Speed: 4})
+	child.SetParent(parent, false)
+	scene.RegisterObject("parent", parent)
+	scene.RegisterObject("child", child)
+
+	buf := &bytes.Buffer{}
+	if err := WriteScene(buf, scene); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadScene(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	readParent, readChild := read.Objects()["parent"], read.Objects()["child"]
+	if readChild.Parent() != readParent {
+		t.Error("parent not restored")
+	}
+	if !readParent.Enabled() || readChild.Enabled() {
+		t.Error("enabled state not restored")
+	}
+	if readParent.LocalPosition() != parent.LocalPosition() || readParent.LocalScale() != parent.LocalScale() {
+		t.Error("transform not restored")
+	}
+	if !readChild.LocalRotation().ApproxEqual(child.LocalRotation()) {
+		t.Error("rotation not restored")
+	}
+	light, ok := GetComponent[*LightComponent](readParent)
+	if !ok || light.Range != 5 || light.LightSource != LIGHT_SOURCE_POINT {
+		t.Errorf("light = %+v", light)
+	}
+	compos := readParent.attached
+	if len(compos) != 3 {
+		t.Fatalf("%d components, want 3", len(compos))
+	}
+	if first, ok := compos[0].(*MeshComponent); !ok || first.Mesh != "first" {
+		t.Errorf("component 0 = %+v, want the first mesh", compos[0])
+	}
+	if _, ok := compos[1].(*LightComponent); !ok {
+		t.Errorf("component 1 = %+v, want the light", compos[1])
+	}
+	if second, ok := compos[2].(*MeshComponent); !ok || second.Mesh != "second" {
+		t.Errorf("component 2 = %+v, want the second mesh", compos[2])
+	}
+	if behaviors := readChild.Behaviors(); len(behaviors) != 1 || behaviors[0].(*sceneFileBehavior).Speed != 4 {
+		t.Errorf("behaviors = %v", behaviors)
+	}
+}
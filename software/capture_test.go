@@ -0,0 +1,250 @@
+package software
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/go-gl/mathgl/mgl32"
+	. "github.com/wxdao/wengine"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newCaptureContext creates a context drawing a white floor seen from above
+// by a camera covering the left half of a 16x8 screen. The right half is
+// never drawn.
+func newCaptureContext() (*Context, *CameraComponent) {
+	ctx := NewContext("software")
+	ctx.SetScreenSize(16, 8)
+	ctx.RegisterAsset("floorMesh", DefaultPlaneMeshAsset())
+	ctx.RegisterAsset("floorMaterial", &MeshMaterialAsset{DiffuseColor: mgl32.Vec4{1, 1, 1, 1}})
+
+	scene := NewScene()
+	camera := &CameraComponent{}
+	camera.ViewportX, camera.ViewportY, camera.ViewportW, camera.ViewportH = 0, 0, 0.5, 1
+	camera.ClearColor, camera.ClearDepth = true, true
+	camera.Mode = CAMERA_MODE_ORTHOGRAPHIC
+	camera.Width = 2
+	camera.NearPlane, camera.FarPlane = 0.1, 10
+	camera.Ambient = mgl32.Vec3{1, 1, 1}
+	cameraObject := NewObject()
+	cameraObject.SetPosition(mgl32.Vec3{0, 5, 0})
+	cameraObject.LookAt(mgl32.Vec3{0, 0, 0}, mgl32.Vec3{0, 0, -1})
+	cameraObject.AttachComponent(camera)
+	cameraObject.SetEnabled(true)
+	scene.RegisterObject("camera", cameraObject)
+
+	floorObject := NewObject()
+	floorObject.Scale(mgl32.Vec3{20, 1, 20})
+	floorObject.AttachComponent(&MeshComponent{Mesh: "floorMesh", Material: "floorMaterial"})
+	floorObject.SetEnabled(true)
+	scene.RegisterObject("floor", floorObject)
+
+	ctx.RegisterScene("scene", scene)
+	ctx.ApplyScene("scene")
+	return ctx, camera
+}
+
+// checkCapture checks that img is the whole screen, white on its left half,
+// or the left half alone if half is set.
+func checkCapture(t *testing.T, img image.Image, half bool) {
+	t.Helper()
+	want := image.Rect(0, 0, 16, 8)
+	if half {
+		want = image.Rect(0, 0, 8, 8)
+	}
+	if img == nil || img.Bounds() != want {
+		t.Fatalf("captured %v, want %v", img, want)
+	}
+	if r, g, b, _ := img.At(2, 4).RGBA(); r>>8 != 255 || g>>8 != 255 || b>>8 != 255 {
+		t.Errorf("floor = %v, %v, %v, want white", r>>8, g>>8, b>>8)
+	}
+	if !half {
+		if r, _, _, _ := img.At(12, 4).RGBA(); r != 0 {
+			t.Errorf("undrawn half = %v, want black", r>>8)
+		}
+	}
+}
+
+func TestCaptureFrame(t *testing.T) {
+	tests := []struct {
+		name   string
+		camera bool
+		async  bool
+	}{
+		{"screen", false, false},
+		{"camera", true, false},
+		{"screen async", false, true},
+		{"camera async", true, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, camera := newCaptureContext()
+			if !test.camera {
+				camera = nil
+			}
+			calls := 0
+			var captured *image.RGBA
+			done := func(img *image.RGBA, err error) {
+				if err != nil {
+					t.Error(err)
+				}
+				calls++
+				captured = img
+			}
+			if test.async {
+				ctx.CaptureFrameAsync(camera, done)
+			} else {
+				ctx.CaptureFrame(camera, done)
+			}
+			if err := ctx.Step(1.0 / 60); err != nil {
+				t.Fatal(err)
+			}
+			if err := ctx.Step(1.0 / 60); err != nil {
+				t.Fatal(err)
+			}
+			if calls != 1 {
+				t.Fatalf("done called %d times, want once", calls)
+			}
+			checkCapture(t, captured, test.camera)
+		})
+	}
+}
+
+func TestCaptureFrameWithoutCapturingRenderer(t *testing.T) {
+	ctx := NewContext("null")
+	ctx.RegisterScene("scene", NewScene())
+	ctx.ApplyScene("scene")
+	var captureErr error
+	ctx.CaptureFrame(nil, func(img *image.RGBA, err error) { captureErr = err })
+	if err := ctx.Step(1.0 / 60); err != nil {
+		t.Fatal(err)
+	}
+	if captureErr == nil {
+		t.Error("no error capturing with the null renderer")
+	}
+	if err := ctx.StartFrameCapture(t.TempDir()); err == nil {
+		t.Error("no error capturing frames with the null renderer")
+	}
+}
+
+func TestScreenshot(t *testing.T) {
+	ctx, _ := newCaptureContext()
+	path := filepath.Join(t.TempDir(), "shot.png")
+	result := make(chan error, 1)
+	ctx.Screenshot(path, func(err error) { result <- err })
+	if err := ctx.Step(1.0 / 60); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+	checkCapture(t, readPNG(t, path), false)
+}
+
+func TestScreenshotKey(t *testing.T) {
+	ctx, _ := newCaptureContext()
+	dir := filepath.Join(t.TempDir(), "shots")
+	type shot struct {
+		path string
+		err  error
+	}
+	result := make(chan shot, 1)
+	ctx.SetScreenshotKey("f12", dir, func(path string, err error) { result <- shot{path, err} })
+	ctx.Input().InjectKey("f12", true)
+	if err := ctx.Step(1.0 / 60); err != nil {
+		t.Fatal(err)
+	}
+	// the key is read after the frame was rendered, the next one is taken
+	if err := ctx.Step(1.0 / 60); err != nil {
+		t.Fatal(err)
+	}
+	got := <-result
+	if got.err != nil {
+		t.Fatal(got.err)
+	}
+	if filepath.Dir(got.path) != dir {
+		t.Errorf("screenshot at %s, want in %s", got.path, dir)
+	}
+	checkCapture(t, readPNG(t, got.path), false)
+}
+
+func TestFrameSequence(t *testing.T) {
+	const frames = 3
+	ctx, _ := newCaptureContext()
+	dir := filepath.Join(t.TempDir(), "frames")
+	if err := ctx.StartFrameCapture(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := ctx.StartFrameStream(&bytes.Buffer{}); err == nil {
+		t.Error("started a second frame capture")
+	}
+	for i := 0; i < frames; i++ {
+		if err := ctx.Step(1.0 / 60); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ctx.StopFrameCapture(); err != nil {
+		t.Fatal(err)
+	}
+	if ctx.CapturingFrames() {
+		t.Error("still capturing frames")
+	}
+	// frames stepped after stopping are not written
+	if err := ctx.Step(1.0 / 60); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != frames {
+		t.Fatalf("%d files, want %d", len(entries), frames)
+	}
+	for i := 0; i < frames; i++ {
+		checkCapture(t, readPNG(t, filepath.Join(dir, fmt.Sprintf("frame-%06d.png", i))), false)
+	}
+}
+
+func TestFrameStream(t *testing.T) {
+	const frames = 2
+	ctx, _ := newCaptureContext()
+	stream := &bytes.Buffer{}
+	if err := ctx.StartFrameStream(stream); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < frames; i++ {
+		if err := ctx.Step(1.0 / 60); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ctx.StopFrameCapture(); err != nil {
+		t.Fatal(err)
+	}
+
+	frameSize := 16 * 8 * 4
+	if stream.Len() != frames*frameSize {
+		t.Fatalf("streamed %d bytes, want %d", stream.Len(), frames*frameSize)
+	}
+	for i := 0; i < frames; i++ {
+		img := &image.RGBA{Pix: stream.Bytes()[i*frameSize : (i+1)*frameSize], Stride: 16 * 4, Rect: image.Rect(0, 0, 16, 8)}
+		checkCapture(t, img, false)
+	}
+}
+
+func readPNG(t *testing.T, path string) image.Image {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	img, err := png.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
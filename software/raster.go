@@ -0,0 +1,226 @@
+package software
+
+import (
+	"github.com/go-gl/mathgl/mgl32"
+	. "github.com/wxdao/wengine"
+	"image"
+	"math"
+)
+
+type vertex struct {
+	clip   mgl32.Vec4
+	world  mgl32.Vec3
+	normal mgl32.Vec3
+	uv     mgl32.Vec2
+}
+
+func lerpVertex(a, b vertex, t float32) vertex {
+	return vertex{
+		clip:   a.clip.Add(b.clip.Sub(a.clip).Mul(t)),
+		world:  a.world.Add(b.world.Sub(a.world).Mul(t)),
+		normal: a.normal.Add(b.normal.Sub(a.normal).Mul(t)),
+		uv:     a.uv.Add(b.uv.Sub(a.uv).Mul(t)),
+	}
+}
+
+// clipNear clips a triangle against the near plane (z >= -w), returning a
+// polygon of up to four vertices.
+func clipNear(tri [3]vertex) []vertex {
+	polygon := make([]vertex, 0, 4)
+	for i := 0; i < 3; i++ {
+		a, b := tri[i], tri[(i+1)%3]
+		da, db := a.clip.Z()+a.clip.W(), b.clip.Z()+b.clip.W()
+		if da >= 0 {
+			polygon = append(polygon, a)
+		}
+		if (da >= 0) != (db >= 0) {
+			polygon = append(polygon, lerpVertex(a, b, da/(da-db)))
+		}
+	}
+	return polygon
+}
+
+// screenVertex is a vertex after perspective division, in image coordinates.
+type screenVertex struct {
+	x, y, z float32
+	// 1/w, used for perspective-correct interpolation
+	invW float32
+	vertex
+}
+
+func (r *SoftwareRenderer) drawTriangle(vp viewport, tri [3]vertex, shading *shadingInput) {
+	polygon := clipNear(tri)
+	if len(polygon) < 3 {
+		return
+	}
+	screen := make([]screenVertex, len(polygon))
+	for i, v := range polygon {
+		invW := 1 / v.clip.W()
+		ndc := v.clip.Vec3().Mul(invW)
+		screen[i] = screenVertex{
+			x:      float32(vp.x) + (ndc.X()*0.5+0.5)*float32(vp.w),
+			y:      float32(vp.y) + (0.5-ndc.Y()*0.5)*float32(vp.h),
+			z:      ndc.Z()*0.5 + 0.5,
+			invW:   invW,
+			vertex: v,
+		}
+	}
+	for i := 1; i+1 < len(screen); i++ {
+		r.fillTriangle(vp, &screen[0], &screen[i], &screen[i+1], shading)
+	}
+}
+
+func edge(a, b *screenVertex, x, y float32) float32 {
+	return (b.x-a.x)*(y-a.y) - (b.y-a.y)*(x-a.x)
+}
+
+func (r *SoftwareRenderer) fillTriangle(vp viewport, v0, v1, v2 *screenVertex, shading *shadingInput) {
+	// image y points down, so counter-clockwise front faces have a negative
+	// area here
+	area := edge(v0, v1, v2.x, v2.y)
+	if area >= 0 {
+		return
+	}
+
+	bounds := r.target.color.Rect
+	minX := max(max(vp.x, 0), int(math.Floor(float64(min3(v0.x, v1.x, v2.x)))))
+	maxX := min(min(vp.x+vp.w, bounds.Dx())-1, int(math.Ceil(float64(max3(v0.x, v1.x, v2.x)))))
+	minY := max(max(vp.y, 0), int(math.Floor(float64(min3(v0.y, v1.y, v2.y)))))
+	maxY := min(min(vp.y+vp.h, bounds.Dy())-1, int(math.Ceil(float64(max3(v0.y, v1.y, v2.y)))))
+
+	for y := minY; y <= maxY; y++ {
+		py := float32(y) + 0.5
+		for x := minX; x <= maxX; x++ {
+			px := float32(x) + 0.5
+			b0 := edge(v1, v2, px, py) / area
+			b1 := edge(v2, v0, px, py) / area
+			b2 := edge(v0, v1, px, py) / area
+			if b0 < 0 || b1 < 0 || b2 < 0 {
+				continue
+			}
+
+			z := b0*v0.z + b1*v1.z + b2*v2.z
+			depthIndex := y*bounds.Dx() + x
+			if z < 0 || z > r.target.depth[depthIndex] {
+				continue
+			}
+
+			// perspective-correct weights
+			w0, w1, w2 := b0*v0.invW, b1*v1.invW, b2*v2.invW
+			sum := w0 + w1 + w2
+			w0, w1, w2 = w0/sum, w1/sum, w2/sum
+
+			frag := fragment{
+				position: v0.world.Mul(w0).Add(v1.world.Mul(w1)).Add(v2.world.Mul(w2)),
+				normal:   v0.normal.Mul(w0).Add(v1.normal.Mul(w1)).Add(v2.normal.Mul(w2)),
+				uv:       v0.uv.Mul(w0).Add(v1.uv.Mul(w1)).Add(v2.uv.Mul(w2)),
+			}
+			r.target.depth[depthIndex] = z
+			r.target.hdr[depthIndex] = shading.shade(&frag)
+			r.target.pass[depthIndex] = r.pass
+		}
+	}
+}
+
+type fragment struct {
+	position mgl32.Vec3
+	normal   mgl32.Vec3
+	uv       mgl32.Vec2
+}
+
+type shadingInput struct {
+	cameraPosition mgl32.Vec3
+	ambient        mgl32.Vec3
+	lights         []*LightComponent
+	material       *MeshMaterialAsset
+	// diffuseColor is the linear diffuse color of the material
+	diffuseColor mgl32.Vec3
+	// diffuseImage is the diffuse map or render texture of the material,
+	// sRGB encoded
+	diffuseImage *image.RGBA
+}
+
+// shade computes the linear color of a fragment the way the opengl shaders
+// do.
+func (s *shadingInput) shade(frag *fragment) mgl32.Vec3 {
+	diffuseColor := s.diffuse(frag.uv)
+	normal := frag.normal.Normalize()
+	viewDirection := frag.position.Sub(s.cameraPosition).Normalize()
+
+	result := mul3(s.ambient, diffuseColor)
+	for _, light := range s.lights {
+		var lightDirection mgl32.Vec3
+		attenuation := float32(1)
+		switch light.LightSource {
+		case LIGHT_SOURCE_DIRECTIONAL:
+			lightDirection = light.Object().Forward()
+		case LIGHT_SOURCE_POINT, LIGHT_SOURCE_SPOT:
+			lightDirection = frag.position.Sub(light.Object().Position())
+			attenuation = float32(math.Max(1-float64(lightDirection.Len()/light.Range), 0))
+			if light.LightSource == LIGHT_SOURCE_SPOT {
+				cosAngle := float32(math.Cos(float64(light.Angle / 2)))
+				if lightDirection.Normalize().Dot(light.Object().Forward().Normalize()) <= cosAngle {
+					attenuation = 0
+				}
+			}
+		default:
+			continue
+		}
+		if attenuation == 0 {
+			continue
+		}
+		halfDirection := viewDirection.Add(lightDirection).Normalize().Mul(-1)
+
+		diffuse := mul3(light.Diffuse, diffuseColor).Mul(float32(math.Max(float64(normal.Dot(lightDirection.Normalize().Mul(-1))), 0)))
+		specular := light.Specular.Mul(float32(math.Pow(math.Max(float64(normal.Dot(halfDirection)), 0), 32)))
+		result = result.Add(diffuse.Add(specular).Mul(attenuation))
+	}
+	return result
+}
+
+// diffuse samples the diffuse image with uv (0, 0) at the bottom left, or
+// returns the diffuse color of the material, linear either way.
+func (s *shadingInput) diffuse(uv mgl32.Vec2) mgl32.Vec3 {
+	img := s.diffuseImage
+	if img == nil {
+		return s.diffuseColor
+	}
+	size := img.Rect.Size()
+	x := wrap(int(math.Floor(float64(uv.X()*float32(size.X)))), size.X)
+	y := wrap(int(math.Floor(float64((1-uv.Y())*float32(size.Y)))), size.Y)
+	offset := img.PixOffset(img.Rect.Min.X+x, img.Rect.Min.Y+y)
+	return mgl32.Vec3{
+		srgbToLinear[img.Pix[offset+0]],
+		srgbToLinear[img.Pix[offset+1]],
+		srgbToLinear[img.Pix[offset+2]],
+	}
+}
+
+// srgbToLinear decodes 8-bit sRGB values.
+var srgbToLinear [256]float32
+
+func init() {
+	for i := range srgbToLinear {
+		srgbToLinear[i] = SRGBToLinear(float32(i) / 255)
+	}
+}
+
+func wrap(v, n int) int {
+	v %= n
+	if v < 0 {
+		v += n
+	}
+	return v
+}
+
+func mul3(a, b mgl32.Vec3) mgl32.Vec3 {
+	return mgl32.Vec3{a[0] * b[0], a[1] * b[1], a[2] * b[2]}
+}
+
+func min3(a, b, c float32) float32 {
+	return float32(math.Min(float64(a), math.Min(float64(b), float64(c))))
+}
+
+func max3(a, b, c float32) float32 {
+	return float32(math.Max(float64(a), math.Max(float64(b), float64(c))))
+}
@@ -0,0 +1,363 @@
+package software
+
+import (
+	"errors"
+	"github.com/go-gl/mathgl/mgl32"
+	. "github.com/wxdao/wengine"
+	"image"
+	"image/draw"
+)
+
+func init() {
+	RegisterRendererFactory("software", func() Renderer { return NewSoftwareRenderer() })
+}
+
+// SoftwareRenderer rasterizes meshes on the CPU into an image. It implements the
+// same lighting, tone mapping and exposure as the opengl renderer, without
+// shadows.
+type SoftwareRenderer struct {
+	context *Context
+
+	screen   renderTarget
+	textures map[string]*renderTarget
+	// target is what the current camera draws into
+	target *renderTarget
+	// pass numbers the cameras drawn, telling the pixels of the current one
+	pass int
+}
+
+// renderTarget is the screen or a render texture. Fragments are shaded into
+// hdr, and tone mapped into color once the camera is done. Render textures
+// are always 8 bits per channel sRGB, whatever their format.
+type renderTarget struct {
+	color *image.RGBA
+	depth []float32
+	hdr   []mgl32.Vec3
+	// pass is the pass that last drew each pixel
+	pass []int
+}
+
+// allocate (re)allocates the buffers if their size differs.
+func (t *renderTarget) allocate(width, height int) {
+	if t.color == nil || t.color.Rect.Dx() != width || t.color.Rect.Dy() != height {
+		t.color = image.NewRGBA(image.Rect(0, 0, width, height))
+		t.depth = make([]float32, width*height)
+		t.hdr = make([]mgl32.Vec3, width*height)
+		t.pass = make([]int, width*height)
+	}
+}
+
+func NewSoftwareRenderer() *SoftwareRenderer {
+	return &SoftwareRenderer{}
+}
+
+func (r *SoftwareRenderer) Init(context *Context) error {
+	r.context = context
+	r.screen = renderTarget{}
+	r.textures = map[string]*renderTarget{}
+	return nil
+}
+
+func (r *SoftwareRenderer) Version() string {
+	return "software 1.0"
+}
+
+// Resize drops the buffers, to be reallocated at the new size by the next
+// Render.
+func (r *SoftwareRenderer) Resize(width, height int) error {
+	r.screen = renderTarget{}
+	return nil
+}
+
+func (r *SoftwareRenderer) NotifyInstall(assets []string) error {
+	return nil
+}
+
+// Image returns the result of the last Render. It is reused by the next one.
+func (r *SoftwareRenderer) Image() *image.RGBA {
+	return r.screen.color
+}
+
+// TextureImage returns the content of the named render texture, or nil if
+// it was never drawn or sampled.
+func (r *SoftwareRenderer) TextureImage(name string) *image.RGBA {
+	if texture, exists := r.textures[name]; exists {
+		return texture.color
+	}
+	return nil
+}
+
+// Capture copies the viewport of camera out of its target, or the whole
+// screen if camera is nil.
+func (r *SoftwareRenderer) Capture(camera *CameraComponent) (*image.RGBA, error) {
+	target := &r.screen
+	if camera != nil && camera.TargetTexture != "" {
+		texture, exists := r.textures[camera.TargetTexture]
+		if !exists {
+			return nil, errors.New("render texture not drawn: " + camera.TargetTexture)
+		}
+		target = texture
+	}
+	if target.color == nil {
+		return nil, errors.New("nothing rendered")
+	}
+	rect := target.color.Rect
+	if camera != nil {
+		vp := r.cameraViewport(camera)
+		rect = image.Rect(vp.x, vp.y, vp.x+vp.w, vp.y+vp.h).Intersect(rect)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(img, img.Rect, target.color, rect.Min, draw.Src)
+	return img, nil
+}
+
+// CaptureAsync has nothing to wait for, done is called right away.
+func (r *SoftwareRenderer) CaptureAsync(camera *CameraComponent, done func(img *image.RGBA, err error)) error {
+	img, err := r.Capture(camera)
+	if err != nil {
+		return err
+	}
+	done(img, nil)
+	return nil
+}
+
+func (r *SoftwareRenderer) FinishCaptures() error {
+	return nil
+}
+
+func (r *SoftwareRenderer) Render(scene *Scene) error {
+	scrWidth, scrHeight := r.context.ScreenSize()
+	if scrWidth <= 0 || scrHeight <= 0 {
+		return errors.New("invalid screen size")
+	}
+	r.screen.allocate(scrWidth, scrHeight)
+
+	cameras := []*CameraComponent{}
+	meshes := []*MeshComponent{}
+	lights := []*LightComponent{}
+	sprites := []*SpriteComponent{}
+	for _, obj := range scene.Objects() {
+		if !obj.ActiveInHierarchy() {
+			continue
+		}
+		for _, compos := range obj.Components() {
+			for _, compo := range compos {
+				switch c := compo.(type) {
+				case *CameraComponent:
+					cameras = append(cameras, c)
+				case *MeshComponent:
+					meshes = append(meshes, c)
+				case *LightComponent:
+					lights = append(lights, c)
+				case *SpriteComponent:
+					sprites = append(sprites, c)
+				}
+			}
+		}
+	}
+	SortCameras(cameras, meshes, sprites, r.context.Assets())
+
+	for _, camera := range cameras {
+		if camera.TargetTexture != "" {
+			texture, err := r.renderTexture(camera.TargetTexture)
+			if err != nil {
+				return err
+			}
+			r.target = texture
+		} else {
+			r.target = &r.screen
+		}
+		cameraMeshes, _ := SkipTargetSamplers(camera, meshes, nil, r.context.Assets())
+		if err := r.renderCamera(camera, cameraMeshes, lights); err != nil {
+			return err
+		}
+	}
+	r.target = nil
+	return nil
+}
+
+// renderTexture returns the named render texture, allocated at its current
+// size.
+func (r *SoftwareRenderer) renderTexture(name string) (*renderTarget, error) {
+	asset, ok := r.context.Assets()[name].(*RenderTextureAsset)
+	if !ok {
+		return nil, errors.New("no such render texture: " + name)
+	}
+	if asset.Width <= 0 || asset.Height <= 0 {
+		return nil, errors.New("invalid render texture size: " + name)
+	}
+	texture, exists := r.textures[name]
+	if !exists {
+		texture = &renderTarget{}
+		r.textures[name] = texture
+	}
+	texture.allocate(asset.Width, asset.Height)
+	return texture, nil
+}
+
+// viewport is a camera's viewport in image coordinates, y pointing down.
+type viewport struct {
+	x, y, w, h int
+}
+
+func (r *SoftwareRenderer) cameraViewport(camera *CameraComponent) viewport {
+	scrWidth, scrHeight := r.context.TargetSize(camera)
+	x := int(float32(scrWidth) * camera.ViewportX)
+	y := int(float32(scrHeight) * camera.ViewportY)
+	w := int(float32(scrWidth) * camera.ViewportW)
+	h := int(float32(scrHeight) * camera.ViewportH)
+	return viewport{x: x, y: scrHeight - y - h, w: w, h: h}
+}
+
+func (r *SoftwareRenderer) renderCamera(camera *CameraComponent, meshes []*MeshComponent, lights []*LightComponent) error {
+	scrWidth, scrHeight := r.context.TargetSize(camera)
+	vp := r.cameraViewport(camera)
+	r.clear(vp, camera.ClearColor, camera.ClearDepth)
+	r.pass++
+
+	viewProjection := camera.ProjectionMatrix(scrWidth, scrHeight).Mul4(camera.ViewMatrix())
+	shading := shadingInput{
+		cameraPosition: camera.Object().Position(),
+		ambient:        camera.Ambient,
+		lights:         lights,
+	}
+
+	for _, mesh := range meshes {
+		meshAsset, err := r.meshAsset(mesh.Mesh)
+		if err != nil {
+			return err
+		}
+		if mesh.Material == "" {
+			return errors.New("mesh with no material")
+		}
+		material, err := r.materialAsset(mesh.Material)
+		if err != nil {
+			return err
+		}
+
+		model := mesh.Object().ModelMatrix()
+		tiModel := model.Mat3().Inv().Transpose()
+		mvp := viewProjection.Mul4(model)
+		shading.material = material
+		shading.diffuseColor = mgl32.Vec3{
+			SRGBToLinear(material.DiffuseColor.X()),
+			SRGBToLinear(material.DiffuseColor.Y()),
+			SRGBToLinear(material.DiffuseColor.Z()),
+		}
+		shading.diffuseImage = material.DiffuseImage
+		if material.RenderTexture != "" {
+			texture, err := r.renderTexture(material.RenderTexture)
+			if err != nil {
+				return err
+			}
+			shading.diffuseImage = texture.color
+		}
+
+		for i := 0; i+2 < len(meshAsset.Vertices); i += 3 {
+			var tri [3]vertex
+			for j := 0; j < 3; j++ {
+				position := meshAsset.Vertices[i+j].Vec4(1)
+				tri[j] = vertex{
+					clip:   mvp.Mul4x1(position),
+					world:  model.Mul4x1(position).Vec3(),
+					normal: tiModel.Mul3x1(meshAsset.Normals[i+j]),
+					uv:     meshAsset.UVs[i+j],
+				}
+			}
+			r.drawTriangle(vp, tri, &shading)
+		}
+	}
+	r.resolve(vp, camera)
+	return nil
+}
+
+// resolve tone maps the pixels drawn by the camera into the color image,
+// adapting its auto exposure to them first.
+func (r *SoftwareRenderer) resolve(vp viewport, camera *CameraComponent) {
+	bounds := r.target.color.Rect
+	minX, maxX := max(vp.x, 0), min(vp.x+vp.w, bounds.Dx())
+	minY, maxY := max(vp.y, 0), min(vp.y+vp.h, bounds.Dy())
+
+	if camera.AutoExposure {
+		histogram := LuminanceHistogram{}
+		for y := minY; y < maxY; y++ {
+			for x := minX; x < maxX; x++ {
+				if i := y*bounds.Dx() + x; r.target.pass[i] == r.pass {
+					histogram.Add(r.target.hdr[i])
+				}
+			}
+		}
+		camera.AdaptExposure(&histogram, r.context.UnscaledDeltaTime())
+	}
+
+	exposure := camera.TotalExposure()
+	for y := minY; y < maxY; y++ {
+		for x := minX; x < maxX; x++ {
+			i := y*bounds.Dx() + x
+			if r.target.pass[i] != r.pass {
+				continue
+			}
+			color := ToneMap(r.target.hdr[i], exposure, camera.ToneMapping)
+			offset := r.target.color.PixOffset(x, y)
+			r.target.color.Pix[offset+0] = uint8(LinearToSRGB(color.X())*255 + 0.5)
+			r.target.color.Pix[offset+1] = uint8(LinearToSRGB(color.Y())*255 + 0.5)
+			r.target.color.Pix[offset+2] = uint8(LinearToSRGB(color.Z())*255 + 0.5)
+			r.target.color.Pix[offset+3] = 255
+		}
+	}
+}
+
+func (r *SoftwareRenderer) clear(vp viewport, color, depth bool) {
+	bounds := r.target.color.Rect
+	for y := max(vp.y, 0); y < min(vp.y+vp.h, bounds.Dy()); y++ {
+		for x := max(vp.x, 0); x < min(vp.x+vp.w, bounds.Dx()); x++ {
+			if color {
+				offset := r.target.color.PixOffset(x, y)
+				copy(r.target.color.Pix[offset:offset+4], []uint8{0, 0, 0, 255})
+			}
+			if depth {
+				r.target.depth[y*bounds.Dx()+x] = 1
+			}
+		}
+	}
+}
+
+func (r *SoftwareRenderer) meshAsset(name string) (*MeshAsset, error) {
+	asset, ok := r.context.Assets()[name].(*MeshAsset)
+	if !ok {
+		return nil, errors.New("no such mesh: " + name)
+	}
+	if !asset.Loaded() {
+		if err := r.context.LoadAssets([]string{name}); err != nil {
+			return nil, err
+		}
+	}
+	return asset, nil
+}
+
+func (r *SoftwareRenderer) materialAsset(name string) (*MeshMaterialAsset, error) {
+	asset, ok := r.context.Assets()[name].(*MeshMaterialAsset)
+	if !ok {
+		return nil, errors.New("no such material: " + name)
+	}
+	if !asset.Loaded() {
+		if err := r.context.LoadAssets([]string{name}); err != nil {
+			return nil, err
+		}
+	}
+	return asset, nil
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,137 @@
+package wengine
+
+import "math"
+
+// clock keeps the game time of a context. Scaled time stops while paused and
+// follows the time scale; unscaled time always follows the frame delta.
+type clock struct {
+	timeScale float64
+	paused    bool
+
+	time, deltaTime                 float64
+	unscaledTime, unscaledDeltaTime float64
+	frameCount                      int
+
+	fixedTimeStep    float64
+	maxFixedSteps    int
+	fixedTime        float64
+	fixedAccumulator float64
+	fixedSteps       int
+}
+
+func newClock() clock {
+	return clock{timeScale: 1, fixedTimeStep: 0.02, maxFixedSteps: 8}
+}
+
+// advance moves the clock forward by the unscaled frame delta and works out
+// how many fixed steps are due in this frame.
+func (c *clock) advance(delta float64) {
+	c.frameCount++
+	c.unscaledDeltaTime = delta
+	c.unscaledTime += delta
+	if c.paused {
+		c.deltaTime = 0
+	} else {
+		c.deltaTime = delta * c.timeScale
+	}
+	c.time += c.deltaTime
+
+	c.fixedAccumulator += c.deltaTime
+	c.fixedSteps = int(c.fixedAccumulator / c.fixedTimeStep)
+	if c.maxFixedSteps > 0 && c.fixedSteps > c.maxFixedSteps {
+		// too far behind, drop the time that cannot be caught up
+		c.fixedSteps = c.maxFixedSteps
+		c.fixedAccumulator = math.Mod(c.fixedAccumulator, c.fixedTimeStep) + float64(c.fixedSteps)*c.fixedTimeStep
+	}
+}
+
+// stepFixed consumes one fixed step, returning false if none is due.
+func (c *clock) stepFixed() bool {
+	if c.fixedSteps == 0 {
+		return false
+	}
+	c.fixedSteps--
+	c.fixedAccumulator -= c.fixedTimeStep
+	c.fixedTime += c.fixedTimeStep
+	return true
+}
+
+// alpha is how far the current frame is between the last fixed step and the
+// next one, in [0, 1).
+func (c *clock) alpha() float64 {
+	return math.Max(0, c.fixedAccumulator/c.fixedTimeStep)
+}
+
+func (ctx *Context) SetTimeScale(scale float64) {
+	if scale < 0 {
+		scale = 0
+	}
+	ctx.clock.timeScale = scale
+}
+
+func (ctx *Context) TimeScale() float64 {
+	return ctx.clock.timeScale
+}
+
+// Pause stops scaled time. Update keeps being called with a zero DeltaTime,
+// while FixedUpdate is not called at all.
+func (ctx *Context) Pause() {
+	ctx.clock.paused = true
+}
+
+func (ctx *Context) Resume() {
+	ctx.clock.paused = false
+}
+
+func (ctx *Context) Paused() bool {
+	return ctx.clock.paused
+}
+
+// SetFixedTimeStep sets the interval of FixedUpdate in seconds.
+func (ctx *Context) SetFixedTimeStep(step float64) {
+	if step <= 0 {
+		return
+	}
+	ctx.clock.fixedTimeStep = step
+}
+
+func (ctx *Context) FixedTimeStep() float64 {
+	return ctx.clock.fixedTimeStep
+}
+
+// SetMaxFixedSteps limits how many fixed steps run in one frame when the
+// frame rate cannot keep up. 0 means no limit.
+func (ctx *Context) SetMaxFixedSteps(steps int) {
+	ctx.clock.maxFixedSteps = steps
+}
+
+// UnscaledDeltaTime returns the unscaled delta of the last frame, for
+// renderers adapting over time.
+func (ctx *Context) UnscaledDeltaTime() float64 {
+	return ctx.clock.unscaledDeltaTime
+}
+
+func (ctx *Context) FrameCount() int {
+	return ctx.clock.frameCount
+}
+
+func (ctx *Context) behaviorContext(object *Object) BehaviorContext {
+	return BehaviorContext{
+		Context:           ctx,
+		Object:            object,
+		DeltaTime:         ctx.clock.deltaTime,
+		Time:              ctx.clock.time,
+		UnscaledDeltaTime: ctx.clock.unscaledDeltaTime,
+		UnscaledTime:      ctx.clock.unscaledTime,
+		FrameCount:        ctx.clock.frameCount,
+		Alpha:             ctx.clock.alpha(),
+	}
+}
+
+func (ctx *Context) fixedBehaviorContext(object *Object) BehaviorContext {
+	bctx := ctx.behaviorContext(object)
+	bctx.DeltaTime = ctx.clock.fixedTimeStep
+	bctx.Time = ctx.clock.fixedTime
+	bctx.Alpha = 0
+	return bctx
+}
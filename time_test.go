@@ -0,0 +1,99 @@
+package wengine
+
+import (
+	"math"
+	"testing"
+)
+
+func TestClockFixedSteps(t *testing.T) {
+	tests := []struct {
+		name      string
+		step      float64
+		maxSteps  int
+		timeScale float64
+		paused    bool
+		deltas    []float64
+		// fixed steps run in each frame
+		want      []int
+		wantAlpha float64
+	}{
+		{"one step per frame", 0.25, 8, 1, false, []float64{0.25, 0.25, 0.25}, []int{1, 1, 1}, 0},
+		{"accumulated", 0.25, 8, 1, false, []float64{0.125, 0.125, 0.125}, []int{0, 1, 0}, 0.5},
+		{"several steps", 0.25, 8, 1, false, []float64{0.75, 0.375}, []int{3, 1}, 0.5},
+		{"capped", 0.25, 2, 1, false, []float64{2.125, 0.25}, []int{2, 1}, 0.5},
+		{"uncapped", 0.25, 0, 1, false, []float64{2.5}, []int{10}, 0},
+		{"time scale", 0.25, 8, 2, false, []float64{0.25, 0.0625}, []int{2, 0}, 0.5},
+		{"paused", 0.25, 8, 1, true, []float64{0.5, 1}, []int{0, 0}, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newClock()
+			c.fixedTimeStep, c.maxFixedSteps, c.timeScale, c.paused = test.step, test.maxSteps, test.timeScale, test.paused
+			total := 0
+			for i, delta := range test.deltas {
+				c.advance(delta)
+				steps := 0
+				for c.stepFixed() {
+					steps++
+				}
+				if steps != test.want[i] {
+					t.Errorf("frame %d: %d fixed steps, want %d", i, steps, test.want[i])
+				}
+				total += steps
+			}
+			if math.Abs(c.alpha()-test.wantAlpha) > 1e-9 {
+				t.Errorf("alpha = %v, want %v", c.alpha(), test.wantAlpha)
+			}
+			if c.fixedTime != float64(total)*test.step {
+				t.Errorf("fixed time = %v, want %v", c.fixedTime, float64(total)*test.step)
+			}
+		})
+	}
+}
+
+func TestClockTime(t *testing.T) {
+	tests := []struct {
+		name         string
+		timeScale    float64
+		paused       bool
+		deltas       []float64
+		wantTime     float64
+		wantDelta    float64
+		wantUnscaled float64
+	}{
+		{"normal", 1, false, []float64{0.5, 0.25}, 0.75, 0.25, 0.75},
+		{"slow motion", 0.5, false, []float64{0.5, 0.25}, 0.375, 0.125, 0.75},
+		{"paused", 1, true, []float64{0.5, 0.25}, 0, 0, 0.75},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := NewHeadlessContext()
+			ctx.SetTimeScale(test.timeScale)
+			if test.paused {
+				ctx.Pause()
+			}
+			for _, delta := range test.deltas {
+				ctx.clock.advance(delta)
+			}
+			bctx := ctx.behaviorContext(nil)
+			if bctx.Time != test.wantTime || bctx.DeltaTime != test.wantDelta || bctx.UnscaledTime != test.wantUnscaled {
+				t.Errorf("time %v delta %v unscaled %v, want %v %v %v", bctx.Time, bctx.DeltaTime, bctx.UnscaledTime, test.wantTime, test.wantDelta, test.wantUnscaled)
+			}
+			if bctx.FrameCount != len(test.deltas) {
+				t.Errorf("frame count = %d, want %d", bctx.FrameCount, len(test.deltas))
+			}
+		})
+	}
+}
+
+func TestSetTimeScaleClamped(t *testing.T) {
+	ctx := NewHeadlessContext()
+	ctx.SetTimeScale(-1)
+	if ctx.TimeScale() != 0 {
+		t.Errorf("time scale = %v, want 0", ctx.TimeScale())
+	}
+	ctx.SetFixedTimeStep(0)
+	if ctx.FixedTimeStep() != 0.02 {
+		t.Errorf("fixed time step = %v, want the default", ctx.FixedTimeStep())
+	}
+}